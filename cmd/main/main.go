@@ -2,67 +2,241 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/realdanielursul/order-service/config"
 	"github.com/realdanielursul/order-service/internal/cache"
 	"github.com/realdanielursul/order-service/internal/consumer"
 	"github.com/realdanielursul/order-service/internal/handler"
+	"github.com/realdanielursul/order-service/internal/outbox"
 	"github.com/realdanielursul/order-service/internal/repository"
 	"github.com/realdanielursul/order-service/internal/service"
+	"github.com/realdanielursul/order-service/pkg/health"
 	"github.com/realdanielursul/order-service/pkg/httpserver"
 	"github.com/realdanielursul/order-service/pkg/kafka"
+	"github.com/realdanielursul/order-service/pkg/lifecycle"
 	"github.com/realdanielursul/order-service/pkg/logger"
 	"github.com/realdanielursul/order-service/pkg/postgres"
 	"github.com/realdanielursul/order-service/pkg/redis"
+	"github.com/realdanielursul/order-service/pkg/retry"
+	"github.com/realdanielursul/order-service/pkg/tracing"
+	goredis "github.com/redis/go-redis/v9"
+	kafkago "github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
+const defaultStartupBackoff = 500 * time.Millisecond
+
 func main() {
-	// Set up logger
-	logger.SetLogrus()
+	configPath := flag.String("config", "", fmt.Sprintf("path to the config file (env %s, default %s)", config.PathEnv, config.DefaultPath))
+	flag.Parse()
+
+	path := config.Path(*configPath)
+
+	// Run a command instead of the app if one is given
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(path, args); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
 
 	// Configure app
-	cfg, err := config.NewConfig("./config/docker.yaml")
+	cfg, err := config.NewConfig(path)
 	if err != nil {
 		logrus.Fatalf("error initializing config: %s", err.Error())
 	}
 
-	// Connect to Redis Client
-	client, err := redis.NewRedisClient(cfg.Redis)
+	// Set up logger
+	if err := logger.SetLogrus(cfg.Log); err != nil {
+		logrus.Fatalf("error initializing logger: %s", err.Error())
+	}
+
+	// Cancel the root context on SIGTERM and shut down in order
+	lc := lifecycle.New(cfg.App.ShutdownTimeout)
+	ctx := lc.Context()
+
+	// Set up tracing
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, cfg.App)
 	if err != nil {
-		logrus.Fatalf("failed to connect to redis client: %s", err.Error())
+		logrus.Fatalf("error initializing tracing: %s", err.Error())
+	}
+
+	// Wait for dependencies that may start after the app, within a total deadline
+	startupCtx, cancelStartup := context.WithCancel(ctx)
+	if cfg.Startup.Timeout > 0 {
+		startupCtx, cancelStartup = context.WithTimeout(ctx, cfg.Startup.Timeout)
 	}
+	defer cancelStartup()
 
-	// Connect to DB
-	db, err := postgres.NewPostgresDB(cfg.Postgres)
+	var (
+		db          *sqlx.DB
+		client      *goredis.Client
+		orderCache  service.OrderCache
+		idempotency handler.IdempotencyStore
+		orderRepo   service.OrderRepository
+		outboxStore outbox.Store
+	)
+
+	if cfg.App.Mode == config.ModeStandalone {
+		// Use in-memory storage instead of Redis and Postgres
+		logrus.Warn("running in standalone mode, orders are kept in memory only")
+
+		memoryCache := cache.NewMemoryCache(cfg.Cache)
+		memoryRepo := repository.NewMemoryRepository()
+
+		orderCache = memoryCache
+		idempotency = memoryCache
+		orderRepo = memoryRepo
+		outboxStore = memoryRepo
+	} else {
+		// Connect to Redis Client
+		err = waitFor(startupCtx, cfg.Startup, "redis", func(ctx context.Context) (err error) {
+			client, err = redis.NewRedisClient(ctx, cfg.Redis)
+			return err
+		})
+		if err != nil {
+			logrus.Fatalf("failed to connect to redis client: %s", err.Error())
+		}
+
+		// Connect to DB
+		err = waitFor(startupCtx, cfg.Startup, "postgres", func(ctx context.Context) (err error) {
+			db, err = postgres.NewPostgresDB(ctx, cfg.Postgres)
+			return err
+		})
+		if err != nil {
+			logrus.Fatalf("failed to connect to db: %s", err.Error())
+		}
+
+		redisCache := cache.NewCache(client, cfg.Cache)
+		lc.Go("cache invalidation", redisCache.RunInvalidation)
+
+		postgresRepo := repository.NewRepository(db)
+
+		orderCache = redisCache
+		idempotency = redisCache
+		orderRepo = postgresRepo
+		outboxStore = postgresRepo
+	}
+
+	// Wait for Kafka, so that the cache preload below does not count against the startup deadline
+	err = waitFor(startupCtx, cfg.Startup, "kafka", func(ctx context.Context) error {
+		return kafka.Ping(ctx, cfg.Kafka)
+	})
 	if err != nil {
-		logrus.Fatalf("failed to connect to db: %s", err.Error())
+		logrus.Fatalf("failed to connect to kafka: %s", err.Error())
 	}
+	cancelStartup()
 
 	// Initialize layers
-	cache := cache.NewCache(client)
-	repository := repository.NewRepository(db)
-	service := service.NewService(cache, repository)
+	service := service.NewService(orderCache, orderRepo, cfg.Service)
 
 	// Preload cache
-	if err := service.PreloadCache(context.Background()); err != nil {
-		logrus.Fatalf("cache preload failed: %v", err)
+	if cfg.Service.Preload.Async {
+		lc.Go("cache preload", func(ctx context.Context) {
+			if err := service.PreloadCache(ctx); err != nil {
+				logrus.Errorf("cache preload failed: %v", err)
+			}
+		})
+	} else if err := service.PreloadCache(ctx); err != nil {
+		logrus.Errorf("cache preload failed: %v", err)
 	}
 
 	// Connect to Kafka
-	reader := kafka.NewKafkaReader(cfg.Kafka)
+	reader := kafka.NewKafkaReader(cfg.Kafka, cfg.Kafka.Topic)
+
+	// Connect dead-letter topic
+	var dlq *kafkago.Writer
+	if cfg.Kafka.DLQTopic != "" {
+		dlq = kafka.NewKafkaWriter(cfg.Kafka, cfg.Kafka.DLQTopic)
+	}
+
+	// Set up readiness checks
+	readiness := health.NewChecker(cfg.Health.Timeout)
+	if db != nil {
+		readiness.Add("postgres", db.PingContext)
+	}
+	if client != nil {
+		readiness.Add("redis", func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		})
+	}
+	readiness.Add("kafka", func(ctx context.Context) error {
+		return kafka.Ping(ctx, cfg.Kafka)
+	})
+	readiness.Add("preload", func(context.Context) error {
+		loaded, done, err := service.PreloadStatus()
+		switch {
+		case !done:
+			return fmt.Errorf("cache preload in progress, %d orders loaded", loaded)
+		case err != nil:
+			return fmt.Errorf("cache preload failed after %d orders: %w", loaded, err)
+		}
+		return nil
+	})
+
+	// Stop accepting HTTP requests first on shutdown
+	srv := &httpserver.Server{}
+	lc.OnShutdown("http server", cfg.HTTP.ShutdownTimeout, srv.Shutdown)
 
 	// Start Kafka consumer
-	consumer.StartConsumer(service, reader)
+	orderConsumer := consumer.StartConsumer(ctx, service, reader, dlq, cfg.Kafka)
+	lc.OnShutdown("kafka consumer", cfg.Kafka.DrainTimeout, orderConsumer.Stop)
+	readiness.Add("consumer", func(context.Context) error {
+		return orderConsumer.Check(cfg.Health.MaxConsumerLag, cfg.Health.ConsumerStaleAfter)
+	})
+
+	// Start status consumer
+	if cfg.Kafka.StatusTopic != "" {
+		statusReader := kafka.NewKafkaReader(cfg.Kafka, cfg.Kafka.StatusTopic)
+		statusConsumer := consumer.StartStatusConsumer(ctx, service, statusReader, dlq, cfg.Kafka)
+		lc.OnShutdown("status consumer", cfg.Kafka.DrainTimeout, statusConsumer.Stop)
+		readiness.Add("status consumer", func(context.Context) error {
+			return statusConsumer.Check(cfg.Health.MaxConsumerLag, cfg.Health.ConsumerStaleAfter)
+		})
+	}
+
+	if dlq != nil {
+		lc.OnShutdown("dead-letter writer", 0, func(context.Context) error {
+			return dlq.Close()
+		})
+	}
+
+	// Start outbox relay
+	if cfg.Outbox.Topic != "" {
+		writer := kafka.NewKafkaWriter(cfg.Kafka, cfg.Outbox.Topic)
+		relay := outbox.NewRelay(outboxStore, writer, cfg.Outbox)
+		lc.Go("outbox relay", relay.Run)
+
+		lc.OnShutdown("outbox writer", 0, func(context.Context) error {
+			return writer.Close()
+		})
+	}
+
+	// Flush spans once the consumers and the relay have finished
+	lc.OnShutdown("tracing", 0, shutdownTracing)
+
+	if client != nil {
+		lc.OnShutdown("redis connection", 0, func(context.Context) error {
+			return client.Close()
+		})
+	}
+
+	if db != nil {
+		lc.OnShutdown("db connection", 0, func(context.Context) error {
+			return db.Close()
+		})
+	}
 
 	// Run HTTP server
-	handler := handler.NewHandler(service)
-	srv := &httpserver.Server{}
+	handler := handler.NewHandler(service, idempotency, readiness, cfg.HTTP)
 
 	go func() {
 		if err := srv.Run(cfg.HTTP.Port, handler.InitRoutes()); err != nil && err != http.ErrServerClosed {
@@ -73,17 +247,64 @@ func main() {
 	logrus.Printf("App '%s %s' Started", cfg.App.Name, cfg.App.Version)
 
 	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
-	<-quit
+	lc.Wait()
+	logrus.Printf("App '%s %s' Shutting Down", cfg.App.Name, cfg.App.Version)
+
+	lc.Shutdown()
+
+	logrus.Printf("App '%s %s' Shutted Down", cfg.App.Name, cfg.App.Version)
+}
+
+// runCommand runs a command given on the command line. The only command is
+// "config print", which writes the effective config with secrets redacted.
+// The config is printed even if it is invalid, and the validation problems
+// are reported afterwards.
+func runCommand(configPath string, args []string) error {
+	if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error initializing config: %w", err)
+		}
 
-	if err := srv.Shutdown(context.Background()); err != nil {
-		logrus.Errorf("error occured on server shutting down: %s", err.Error())
+		if err := cfg.Print(os.Stdout); err != nil {
+			return err
+		}
+
+		return cfg.Validate()
 	}
 
-	if err := db.Close(); err != nil {
-		logrus.Errorf("error occured on db connection close: %s", err.Error())
+	return fmt.Errorf("unknown command %q, the only command is \"config print\"", strings.Join(args, " "))
+}
+
+// waitFor calls connect until it succeeds, retrying with backoff while ctx
+// allows. A zero startup timeout means a single attempt.
+func waitFor(ctx context.Context, cfg config.Startup, name string, connect func(ctx context.Context) error) error {
+	policy := retry.Policy{Initial: cfg.RetryBackoff, Max: cfg.MaxRetryBackoff}
+	if policy.Initial <= 0 {
+		policy.Initial = defaultStartupBackoff
+	}
+	if cfg.Timeout <= 0 {
+		policy.MaxAttempts = 1
 	}
 
-	logrus.Printf("App '%s %s' Shutted Down", cfg.App.Name, cfg.App.Version)
+	var (
+		attempt int
+		lastErr error
+	)
+	err := retry.Do(ctx, policy, func(error) bool { return true }, func(ctx context.Context) error {
+		attempt++
+
+		lastErr = connect(ctx)
+		if lastErr != nil && policy.MaxAttempts != 1 {
+			logrus.Warnf("%s is not available yet (attempt %d), retrying: %v", name, attempt, lastErr)
+		}
+
+		return lastErr
+	})
+	if err != nil && lastErr != nil {
+		// report why the dependency failed rather than the expired deadline
+		return lastErr
+	}
+
+	return err
 }
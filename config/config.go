@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -9,46 +11,180 @@ import (
 type (
 	Config struct {
 		App      `yaml:"app"`
+		Log      `yaml:"log"`
 		HTTP     `yaml:"http"`
 		Postgres `yaml:"postgres"`
 		Redis    `yaml:"redis"`
+		Cache    `yaml:"cache"`
 		Kafka    `yaml:"kafka"`
+		Outbox   `yaml:"outbox"`
+		Service  `yaml:"service"`
+		Health   `yaml:"health"`
+		Tracing  `yaml:"tracing"`
+		Startup  `yaml:"startup"`
 	}
 
 	App struct {
-		Name    string `yaml:"name"`
-		Version string `yaml:"version"`
+		Name    string `yaml:"name" env:"APP_NAME" env-default:"order-service"`
+		Version string `yaml:"version" env:"APP_VERSION"`
+		Mode    string `yaml:"mode" env:"APP_MODE"` // "standalone" runs on in-memory storage instead of Postgres and Redis
+
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"APP_SHUTDOWN_TIMEOUT"` // bound for each shutdown step without its own
+	}
+
+	Log struct {
+		Level  string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`   // logrus level, info by default
+		Format string `yaml:"format" env:"LOG_FORMAT" env-default:"json"` // "json" or "text"
 	}
 
 	HTTP struct {
-		Port string `yaml:"port"`
+		Port           string        `yaml:"port" env:"HTTP_PORT" env-default:"8080"`
+		MaxBodyBytes   int64         `yaml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES"`
+		MaxBatchSize   int           `yaml:"max_batch_size" env:"HTTP_MAX_BATCH_SIZE"`
+		IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"HTTP_IDEMPOTENCY_TTL"`
+
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
 	}
 
 	Postgres struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password" env:"DB_PASSWORD"`
-		Database string `yaml:"database"`
-		SSLMode  string `yaml:"ssl_mode"`
+		Host     string `yaml:"host" env:"POSTGRES_HOST" env-default:"localhost"`
+		Port     string `yaml:"port" env:"POSTGRES_PORT" env-default:"5432"`
+		Username string `yaml:"username" env:"POSTGRES_USERNAME"`
+		Password string `yaml:"password" env:"POSTGRES_PASSWORD,DB_PASSWORD" secret:"true"`
+		Database string `yaml:"database" env:"POSTGRES_DATABASE"`
+		SSLMode  string `yaml:"ssl_mode" env:"POSTGRES_SSL_MODE" env-default:"disable"`
+
+		MaxOpenConns     int           `yaml:"max_open_conns" env:"POSTGRES_MAX_OPEN_CONNS"`         // 0 means unlimited
+		MaxIdleConns     int           `yaml:"max_idle_conns" env:"POSTGRES_MAX_IDLE_CONNS"`         // 0 keeps the driver default of 2
+		ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"POSTGRES_CONN_MAX_LIFETIME"`   // 0 keeps connections forever
+		ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env:"POSTGRES_CONN_MAX_IDLE_TIME"` // 0 keeps idle connections forever
+		StatementTimeout time.Duration `yaml:"statement_timeout" env:"POSTGRES_STATEMENT_TIMEOUT"`   // enforced by postgres, 0 disables
 	}
 
 	Redis struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		Password string `yaml:"password" env:"REDIS_PASSWORD"`
-		DB       int    `yaml:"db"`
+		Host     string `yaml:"host" env:"REDIS_HOST" env-default:"localhost"`
+		Port     string `yaml:"port" env:"REDIS_PORT" env-default:"6379"`
+		Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+		DB       int    `yaml:"db" env:"REDIS_DB"`
+	}
+
+	Cache struct {
+		RedisTTL        time.Duration `yaml:"redis_ttl" env:"CACHE_REDIS_TTL"`
+		LocalTTL        time.Duration `yaml:"local_ttl" env:"CACHE_LOCAL_TTL"`
+		LocalMaxEntries int           `yaml:"local_max_entries" env:"CACHE_LOCAL_MAX_ENTRIES"` // 0 together with local_max_bytes disables the local tier
+		LocalMaxBytes   int64         `yaml:"local_max_bytes" env:"CACHE_LOCAL_MAX_BYTES"`
+
+		InvalidationChannel string        `yaml:"invalidation_channel" env:"CACHE_INVALIDATION_CHANNEL"` // empty disables cross-instance invalidation
+		ResyncInterval      time.Duration `yaml:"resync_interval" env:"CACHE_RESYNC_INTERVAL"`           // period of full local tier purges, 0 disables
 	}
 
 	Kafka struct {
-		Host    string `yaml:"host"`
-		Port    string `yaml:"port"`
-		Topic   string `yaml:"topic"`
-		GroupID string `yaml:"group_id"`
+		Host        string `yaml:"host" env:"KAFKA_HOST" env-default:"localhost"`
+		Port        string `yaml:"port" env:"KAFKA_PORT" env-default:"9092"`
+		Topic       string `yaml:"topic" env:"KAFKA_TOPIC"`
+		GroupID     string `yaml:"group_id" env:"KAFKA_GROUP_ID"`
+		DLQTopic    string `yaml:"dlq_topic" env:"KAFKA_DLQ_TOPIC"`
+		StatusTopic string `yaml:"status_topic" env:"KAFKA_STATUS_TOPIC"` // empty disables the status consumer
+
+		Workers         int           `yaml:"workers" env:"KAFKA_WORKERS"`
+		WorkerQueueSize int           `yaml:"worker_queue_size" env:"KAFKA_WORKER_QUEUE_SIZE"`
+		BatchSize       int           `yaml:"batch_size" env:"KAFKA_BATCH_SIZE"` // orders stored per transaction, 0 or 1 disables micro-batching
+		BatchTimeout    time.Duration `yaml:"batch_timeout" env:"KAFKA_BATCH_TIMEOUT"`
+		DrainTimeout    time.Duration `yaml:"drain_timeout" env:"KAFKA_DRAIN_TIMEOUT"` // time given to in-flight messages on shutdown
+
+		MaxRetries      int           `yaml:"max_retries" env:"KAFKA_MAX_RETRIES"`
+		RetryBackoff    time.Duration `yaml:"retry_backoff" env:"KAFKA_RETRY_BACKOFF"`
+		MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" env:"KAFKA_MAX_RETRY_BACKOFF"`
+
+		StatusWaitTimeout time.Duration `yaml:"status_wait_timeout" env:"KAFKA_STATUS_WAIT_TIMEOUT"` // how long a status update waits for its order to be stored, 0 to reject it right away
+	}
+
+	Outbox struct {
+		Topic        string        `yaml:"topic" env:"OUTBOX_TOPIC"` // empty disables the relay
+		PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+		BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+		Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"` // how long sent messages are kept, 0 keeps them forever
+	}
+
+	Service struct {
+		ConflictPolicy string        `yaml:"conflict_policy" env:"SERVICE_CONFLICT_POLICY" env-default:"reject"`
+		CoalesceReads  bool          `yaml:"coalesce_reads" env:"SERVICE_COALESCE_READS"`
+		NegativeTTL    time.Duration `yaml:"negative_ttl" env:"SERVICE_NEGATIVE_TTL"` // 0 disables negative caching
+		Preload        Preload       `yaml:"preload"`
+	}
+
+	Preload struct {
+		Enabled   bool          `yaml:"enabled" env:"SERVICE_PRELOAD_ENABLED"`
+		Limit     int           `yaml:"limit" env:"SERVICE_PRELOAD_LIMIT"`   // most recent orders to load, 0 for all
+		Window    time.Duration `yaml:"window" env:"SERVICE_PRELOAD_WINDOW"` // only orders created within the window, 0 for all
+		BatchSize int           `yaml:"batch_size" env:"SERVICE_PRELOAD_BATCH_SIZE"`
+		Async     bool          `yaml:"async" env:"SERVICE_PRELOAD_ASYNC"`
+	}
+
+	Health struct {
+		Timeout            time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT"`                           // bound for all readiness checks together
+		MaxConsumerLag     int64         `yaml:"max_consumer_lag" env:"HEALTH_MAX_CONSUMER_LAG"`         // 0 disables the lag check
+		ConsumerStaleAfter time.Duration `yaml:"consumer_stale_after" env:"HEALTH_CONSUMER_STALE_AFTER"` // 0 disables the staleness check
+	}
+
+	Tracing struct {
+		Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"` // "otlp", "stdout" or "none"
+		Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`                    // OTLP/HTTP collector address, host:port
+		Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE"`
+		SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // share of new traces recorded, above 0 and up to 1
+	}
+
+	Startup struct {
+		Timeout         time.Duration `yaml:"timeout" env:"STARTUP_TIMEOUT"` // total time to wait for postgres, redis and kafka, 0 tries once
+		RetryBackoff    time.Duration `yaml:"retry_backoff" env:"STARTUP_RETRY_BACKOFF"`
+		MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" env:"STARTUP_MAX_RETRY_BACKOFF"`
 	}
 )
 
+const (
+	ModeDefault    = "default"
+	ModeStandalone = "standalone"
+)
+
+const (
+	// DefaultPath is the config file used when neither the -config flag
+	// nor PathEnv is set.
+	DefaultPath = "./config/docker.yaml"
+	PathEnv     = "CONFIG_PATH"
+)
+
+// Path returns the config file to load: flagValue if set, then the
+// CONFIG_PATH environment variable, then DefaultPath.
+func Path(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+
+	return DefaultPath
+}
+
+// NewConfig reads the config file, applies environment overrides and
+// defaults, and validates the result.
 func NewConfig(configPath string) (*Config, error) {
+	cfg, err := Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Load reads the config file and applies environment overrides and defaults
+// without validating the result.
+func Load(configPath string) (*Config, error) {
 	cfg := &Config{}
 
 	if err := cleanenv.ReadConfig(configPath, cfg); err != nil {
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "<redacted>"
+
+// Print writes the config to w as YAML, with every field annotated with the
+// environment variables that override it. Fields tagged secret are redacted.
+func (c *Config) Print(w io.Writer) error {
+	node, err := encodeNode(reflect.ValueOf(*c))
+	if err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(node); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	return enc.Close()
+}
+
+// encodeNode builds the YAML node of a config struct, keeping the field order
+// and printing durations the way they are written in config files.
+func encodeNode(v reflect.Value) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+
+		var (
+			value *yaml.Node
+			err   error
+		)
+		switch fv := v.Field(i); {
+		case fv.Kind() == reflect.Struct:
+			value, err = encodeNode(fv)
+		case field.Tag.Get("secret") == "true" && !fv.IsZero():
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: redacted}
+		case fv.Type() == reflect.TypeOf(time.Duration(0)):
+			value = &yaml.Node{Kind: yaml.ScalarNode, Value: fv.Interface().(time.Duration).String()}
+		default:
+			value = &yaml.Node{}
+			err = value.Encode(fv.Interface())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", name, err)
+		}
+
+		if env := field.Tag.Get("env"); env != "" {
+			value.LineComment = strings.ReplaceAll(env, ",", ", ")
+		}
+
+		node.Content = append(node.Content, key, value)
+	}
+
+	return node, nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.Problems, "; "))
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.addf("%s is required", field)
+	}
+}
+
+func (v *validator) port(field, value string) {
+	if value == "" {
+		v.addf("%s is required", field)
+		return
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		v.addf("%s must be a port number between 1 and 65535, got %q", field, value)
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.addf("%s must be one of %q, got %q", field, allowed, value)
+	}
+}
+
+func (v *validator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.addf("%s must not be negative, got %d", field, value)
+	}
+}
+
+func (v *validator) nonNegativeDuration(field string, value time.Duration) {
+	if value < 0 {
+		v.addf("%s must not be negative, got %s", field, value)
+	}
+}
+
+// Validate checks the config and reports all problems at once.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.required("app.name", c.App.Name)
+	v.oneOf("app.mode", c.App.Mode, "", ModeDefault, ModeStandalone)
+	v.nonNegativeDuration("app.shutdown_timeout", c.App.ShutdownTimeout)
+
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		v.addf("log.level: %v", err)
+	}
+	v.oneOf("log.format", c.Log.Format, "json", "text")
+
+	v.port("http.port", c.HTTP.Port)
+	v.nonNegative("http.max_body_bytes", c.HTTP.MaxBodyBytes)
+	v.nonNegative("http.max_batch_size", int64(c.HTTP.MaxBatchSize))
+	v.nonNegativeDuration("http.idempotency_ttl", c.HTTP.IdempotencyTTL)
+	v.nonNegativeDuration("http.shutdown_timeout", c.HTTP.ShutdownTimeout)
+
+	// postgres and redis are not used in standalone mode
+	if c.App.Mode != ModeStandalone {
+		v.required("postgres.host", c.Postgres.Host)
+		v.port("postgres.port", c.Postgres.Port)
+		v.required("postgres.username", c.Postgres.Username)
+		v.required("postgres.database", c.Postgres.Database)
+		v.oneOf("postgres.ssl_mode", c.Postgres.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+		v.nonNegative("postgres.max_open_conns", int64(c.Postgres.MaxOpenConns))
+		v.nonNegative("postgres.max_idle_conns", int64(c.Postgres.MaxIdleConns))
+		if c.Postgres.MaxOpenConns > 0 && c.Postgres.MaxIdleConns > c.Postgres.MaxOpenConns {
+			v.addf("postgres.max_idle_conns must not exceed postgres.max_open_conns %d, got %d", c.Postgres.MaxOpenConns, c.Postgres.MaxIdleConns)
+		}
+		v.nonNegativeDuration("postgres.conn_max_lifetime", c.Postgres.ConnMaxLifetime)
+		v.nonNegativeDuration("postgres.conn_max_idle_time", c.Postgres.ConnMaxIdleTime)
+		v.nonNegativeDuration("postgres.statement_timeout", c.Postgres.StatementTimeout)
+
+		v.required("redis.host", c.Redis.Host)
+		v.port("redis.port", c.Redis.Port)
+		v.nonNegative("redis.db", int64(c.Redis.DB))
+	}
+
+	v.nonNegativeDuration("cache.redis_ttl", c.Cache.RedisTTL)
+	v.nonNegativeDuration("cache.local_ttl", c.Cache.LocalTTL)
+	v.nonNegative("cache.local_max_entries", int64(c.Cache.LocalMaxEntries))
+	v.nonNegative("cache.local_max_bytes", c.Cache.LocalMaxBytes)
+	v.nonNegativeDuration("cache.resync_interval", c.Cache.ResyncInterval)
+
+	v.required("kafka.host", c.Kafka.Host)
+	v.port("kafka.port", c.Kafka.Port)
+	v.required("kafka.topic", c.Kafka.Topic)
+	v.required("kafka.group_id", c.Kafka.GroupID)
+	for _, t := range []struct{ field, topic string }{
+		{"kafka.dlq_topic", c.Kafka.DLQTopic},
+		{"kafka.status_topic", c.Kafka.StatusTopic},
+		{"outbox.topic", c.Outbox.Topic},
+	} {
+		if t.topic != "" && t.topic == c.Kafka.Topic {
+			v.addf("%s must differ from kafka.topic %q", t.field, c.Kafka.Topic)
+		}
+	}
+	v.nonNegative("kafka.workers", int64(c.Kafka.Workers))
+	v.nonNegative("kafka.worker_queue_size", int64(c.Kafka.WorkerQueueSize))
+	v.nonNegative("kafka.batch_size", int64(c.Kafka.BatchSize))
+	v.nonNegativeDuration("kafka.batch_timeout", c.Kafka.BatchTimeout)
+	v.nonNegativeDuration("kafka.drain_timeout", c.Kafka.DrainTimeout)
+	v.nonNegative("kafka.max_retries", int64(c.Kafka.MaxRetries))
+	v.nonNegativeDuration("kafka.retry_backoff", c.Kafka.RetryBackoff)
+	v.nonNegativeDuration("kafka.max_retry_backoff", c.Kafka.MaxRetryBackoff)
+	v.nonNegativeDuration("kafka.status_wait_timeout", c.Kafka.StatusWaitTimeout)
+
+	v.nonNegativeDuration("outbox.poll_interval", c.Outbox.PollInterval)
+	v.nonNegative("outbox.batch_size", int64(c.Outbox.BatchSize))
+	v.nonNegativeDuration("outbox.retention", c.Outbox.Retention)
+
+	v.oneOf("service.conflict_policy", c.Service.ConflictPolicy, "reject", "update")
+	v.nonNegativeDuration("service.negative_ttl", c.Service.NegativeTTL)
+	v.nonNegative("service.preload.limit", int64(c.Service.Preload.Limit))
+	v.nonNegativeDuration("service.preload.window", c.Service.Preload.Window)
+	v.nonNegative("service.preload.batch_size", int64(c.Service.Preload.BatchSize))
+
+	v.nonNegativeDuration("health.timeout", c.Health.Timeout)
+	v.nonNegative("health.max_consumer_lag", c.Health.MaxConsumerLag)
+	v.nonNegativeDuration("health.consumer_stale_after", c.Health.ConsumerStaleAfter)
+
+	v.oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.Exporter == "otlp" {
+		v.required("tracing.endpoint", c.Tracing.Endpoint)
+	}
+	// a zero ratio is what an unset sample_ratio reads as, and would record
+	// nothing while looking enabled
+	if c.Tracing.Exporter != "none" && (c.Tracing.SampleRatio <= 0 || c.Tracing.SampleRatio > 1) {
+		v.addf("tracing.sample_ratio must be above 0 and at most 1 when tracing is enabled, got %g", c.Tracing.SampleRatio)
+	}
+
+	v.nonNegativeDuration("startup.timeout", c.Startup.Timeout)
+	v.nonNegativeDuration("startup.retry_backoff", c.Startup.RetryBackoff)
+	v.nonNegativeDuration("startup.max_retry_backoff", c.Startup.MaxRetryBackoff)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+
+	return nil
+}
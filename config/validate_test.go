@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTracingSampleRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		ratio    float64
+		valid    bool
+	}{
+		{name: "enabled", exporter: "stdout", ratio: 0.5, valid: true},
+		{name: "enabled with full ratio", exporter: "stdout", ratio: 1, valid: true},
+		{name: "enabled with unset ratio", exporter: "stdout", ratio: 0},
+		{name: "enabled with ratio above 1", exporter: "stdout", ratio: 2},
+		{name: "disabled with unset ratio", exporter: "none", ratio: 0, valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load("local.yaml")
+			if err != nil {
+				t.Fatalf("load config: %v", err)
+			}
+
+			cfg.Tracing.Exporter = tt.exporter
+			cfg.Tracing.SampleRatio = tt.ratio
+
+			err = cfg.Validate()
+
+			var verr *ValidationError
+			switch {
+			case tt.valid && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case !tt.valid && !errors.As(err, &verr):
+				t.Errorf("expected *ValidationError, got %v", err)
+			case !tt.valid && len(verr.Problems) != 1:
+				t.Errorf("expected only the sample_ratio problem, got %v", verr.Problems)
+			}
+		})
+	}
+}
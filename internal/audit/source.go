@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+)
+
+const unknownSource = "unknown"
+
+type (
+	sourceKey       struct{}
+	orderSourcesKey struct{}
+)
+
+// WithSource attaches the origin of a write (Kafka message, HTTP caller) to
+// ctx. The repository stores it with every audit and status history record.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the source attached to ctx, or "unknown".
+func SourceFrom(ctx context.Context) string {
+	if source, ok := ctx.Value(sourceKey{}).(string); ok && source != "" {
+		return source
+	}
+
+	return unknownSource
+}
+
+// WithOrderSources attaches the source of every order of a batched write to
+// ctx. Sources are keyed by the order itself rather than its order_uid, since
+// a batch may hold the same order_uid more than once.
+func WithOrderSources(ctx context.Context, sources map[*entity.Order]string) context.Context {
+	return context.WithValue(ctx, orderSourcesKey{}, sources)
+}
+
+// SourceFor returns the source of order attached by WithOrderSources, or the
+// source of the whole write otherwise.
+func SourceFor(ctx context.Context, order *entity.Order) string {
+	if sources, ok := ctx.Value(orderSourcesKey{}).(map[*entity.Order]string); ok && sources[order] != "" {
+		return sources[order]
+	}
+
+	return SourceFrom(ctx)
+}
+
+func KafkaSource(topic string, partition int, offset int64) string {
+	return fmt.Sprintf("kafka:%s/%d/%d", topic, partition, offset)
+}
+
+func HTTPSource(clientIP string) string {
+	return "http:" + clientIP
+}
@@ -2,34 +2,212 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"net"
 	"time"
 
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/metrics"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-const cacheTTL = time.Hour
+const (
+	defaultRedisTTL = time.Hour
+	defaultLocalTTL = time.Minute
+)
+
+// negativeValue marks a key that is known to have no backing order.
+var negativeValue = []byte("\x00missing")
 
+// ErrNegativeHit is returned by GetData, wrapped in errs.ErrNotFound, when the
+// key was cached as missing with SetMissing.
+var ErrNegativeHit = errors.New("cached as missing")
+
+// Cache is a two-tier cache: an optional in-process LRU in front of Redis.
+// Reads are served from the local tier first; when Redis fails, an expired
+// local entry is served rather than failing the read.
 type Cache struct {
 	*redis.Client
+
+	local    *LRU
+	redisTTL time.Duration
+	localTTL time.Duration
+
+	channel        string
+	resyncInterval time.Duration
+	instanceID     string
+}
+
+func NewCache(client *redis.Client, cfg config.Cache) *Cache {
+	c := &Cache{
+		Client:         client,
+		redisTTL:       cfg.RedisTTL,
+		localTTL:       cfg.LocalTTL,
+		channel:        cfg.InvalidationChannel,
+		resyncInterval: cfg.ResyncInterval,
+		instanceID:     newInstanceID(),
+	}
+
+	if c.redisTTL <= 0 {
+		c.redisTTL = defaultRedisTTL
+	}
+
+	if c.localTTL <= 0 {
+		c.localTTL = defaultLocalTTL
+	}
+
+	if cfg.LocalMaxEntries > 0 || cfg.LocalMaxBytes > 0 {
+		c.local = NewLRU(cfg.LocalMaxEntries, cfg.LocalMaxBytes)
+	}
+
+	return c
+}
+
+func (c *Cache) SetData(ctx context.Context, key string, value []byte) (err error) {
+	ctx, span := startSpan(ctx, "cache.SetData", attribute.String("cache.key", key))
+	defer func() { endSpan(span, err) }()
+
+	c.setLocal(key, value, c.localTTL)
+	return c.redisErr(c.Set(ctx, key, value, c.redisTTL).Err())
+}
+
+// SetMany writes all entries in a single pipelined round-trip.
+func (c *Cache) SetMany(ctx context.Context, entries map[string][]byte) (err error) {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "cache.SetMany", attribute.Int("cache.entries", len(entries)))
+	defer func() { endSpan(span, err) }()
+
+	for key, value := range entries {
+		c.setLocal(key, value, c.localTTL)
+	}
+
+	_, err = c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range entries {
+			pipe.Set(ctx, key, value, c.redisTTL)
+		}
+
+		return nil
+	})
+
+	return c.redisErr(err)
+}
+
+// GetData returns errs.ErrNotFound on a cache miss.
+func (c *Cache) GetData(ctx context.Context, key string) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "cache.GetData", attribute.String("cache.key", key))
+	defer func() { endSpan(span, err) }()
+
+	if c.local != nil {
+		if value, ok := c.local.Get(key); ok {
+			metrics.CacheTierRequests.WithLabelValues(metrics.TierLocal, metrics.TierHit).Inc()
+			span.SetAttributes(attribute.String("cache.tier", "local"))
+			return decodeValue(value)
+		}
+
+		metrics.CacheTierRequests.WithLabelValues(metrics.TierLocal, metrics.TierMiss).Inc()
+	}
+
+	res, err := c.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			metrics.CacheTierRequests.WithLabelValues(metrics.TierRedis, metrics.TierMiss).Inc()
+			return nil, classifyError(err)
+		}
+
+		// keep answering from the local tier while redis is unavailable
+		if c.local != nil {
+			if value, ok := c.local.GetStale(key); ok {
+				metrics.CacheTierRequests.WithLabelValues(metrics.TierLocal, metrics.TierStale).Inc()
+				span.SetAttributes(attribute.String("cache.tier", "stale"))
+				return decodeValue(value)
+			}
+		}
+
+		return nil, c.redisErr(err)
+	}
+
+	metrics.CacheTierRequests.WithLabelValues(metrics.TierRedis, metrics.TierHit).Inc()
+	span.SetAttributes(attribute.String("cache.tier", "redis"))
+	c.setLocal(key, res, c.localTTL)
+
+	return decodeValue(res)
+}
+
+// SetMissing remembers for ttl that key has no backing order. A later
+// SetData for the key replaces the negative entry.
+func (c *Cache) SetMissing(ctx context.Context, key string, ttl time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "cache.SetMissing", attribute.String("cache.key", key))
+	defer func() { endSpan(span, err) }()
+
+	c.setLocal(key, negativeValue, min(ttl, c.localTTL))
+	return c.redisErr(c.Set(ctx, key, negativeValue, ttl).Err())
+}
+
+func (c *Cache) DeleteData(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "cache.DeleteData", attribute.String("cache.key", key))
+	defer func() { endSpan(span, err) }()
+
+	if c.local != nil {
+		c.local.Delete(key)
+		c.observeLocal()
+	}
+
+	if err := c.redisErr(c.Client.Del(ctx, key).Err()); err != nil {
+		return err
+	}
+
+	return c.Invalidate(ctx, key)
 }
 
-func NewCache(client *redis.Client) *Cache {
-	return &Cache{client}
+func (c *Cache) setLocal(key string, value []byte, ttl time.Duration) {
+	if c.local != nil {
+		c.local.Set(key, value, ttl)
+		c.observeLocal()
+	}
 }
 
-func (c *Cache) SetData(ctx context.Context, key string, value []byte) error {
-	return c.Set(ctx, key, value, cacheTTL).Err()
+// observeLocal publishes the size of the local tier.
+func (c *Cache) observeLocal() {
+	entries, bytes := c.local.Len()
+	metrics.CacheLocalEntries.Set(float64(entries))
+	metrics.CacheLocalBytes.Set(float64(bytes))
 }
 
-func (c *Cache) GetData(ctx context.Context, key string) ([]byte, error) {
-	res, err := c.Get(ctx, key).Result()
+func (c *Cache) redisErr(err error) error {
 	if err != nil {
-		return nil, err
+		metrics.CacheTierRequests.WithLabelValues(metrics.TierRedis, metrics.TierError).Inc()
 	}
 
-	return []byte(res), nil
+	return classifyError(err)
 }
 
-func (r *Cache) DeleteData(ctx context.Context, key string) error {
-	return r.Client.Del(ctx, key).Err()
+func decodeValue(value []byte) ([]byte, error) {
+	if string(value) == string(negativeValue) {
+		return nil, errs.Wrap(errs.ErrNotFound, ErrNegativeHit)
+	}
+
+	return value, nil
+}
+
+// classifyError maps redis client errors onto domain errors.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, redis.Nil) {
+		return errs.Wrap(errs.ErrNotFound, err)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errs.Wrap(errs.ErrTimeout, err)
+	}
+
+	return errs.Wrap(errs.ErrUnavailable, err)
 }
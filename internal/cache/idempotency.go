@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKeyPrefix = "idempotency:"
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// Idempotency-Key. A pending record marks a request that is still running.
+type IdempotencyRecord struct {
+	RequestHash string          `json:"request_hash"`
+	Pending     bool            `json:"pending,omitempty"`
+	Status      int             `json:"status,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+}
+
+// Reserve stores a pending record for key unless one exists already. It
+// returns the existing record and false if the key has been used before.
+func (c *Cache) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	data, err := json.Marshal(IdempotencyRecord{RequestHash: requestHash, Pending: true})
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := c.SetNX(ctx, idempotencyKeyPrefix+key, data, ttl).Result()
+	if err != nil {
+		return nil, false, c.redisErr(err)
+	}
+
+	if ok {
+		return nil, true, nil
+	}
+
+	data, err = c.Get(ctx, idempotencyKeyPrefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// expired in between, try again
+			return c.Reserve(ctx, key, requestHash, ttl)
+		}
+
+		return nil, false, c.redisErr(err)
+	}
+
+	var rec IdempotencyRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false, fmt.Errorf("unmarshal idempotency record: %w", err)
+	}
+
+	return &rec, false, nil
+}
+
+// Complete replaces the pending record for key with the final outcome.
+func (c *Cache) Complete(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	return c.redisErr(c.Set(ctx, idempotencyKeyPrefix+key, data, ttl).Err())
+}
+
+// Release forgets key so that the request can be retried.
+func (c *Cache) Release(ctx context.Context, key string) error {
+	return c.redisErr(c.Del(ctx, idempotencyKeyPrefix+key).Err())
+}
+
+// memoryIdempotencySweepInterval is how often MemoryCache drops expired
+// idempotency records.
+const memoryIdempotencySweepInterval = time.Minute
+
+// memoryIdempotency keeps idempotency records for MemoryCache.
+type memoryIdempotency struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyRecord
+	lastSweep time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	rec       IdempotencyRecord
+	expiresAt time.Time
+}
+
+func (c *MemoryCache) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	c.idempotency.mu.Lock()
+	defer c.idempotency.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.idempotency.lastSweep) >= memoryIdempotencySweepInterval {
+		maps.DeleteFunc(c.idempotency.records, func(_ string, r memoryIdempotencyRecord) bool {
+			return !now.Before(r.expiresAt)
+		})
+		c.idempotency.lastSweep = now
+	}
+
+	if existing, ok := c.idempotency.records[key]; ok && now.Before(existing.expiresAt) {
+		rec := existing.rec
+		return &rec, false, nil
+	}
+
+	c.idempotency.records[key] = memoryIdempotencyRecord{
+		rec:       IdempotencyRecord{RequestHash: requestHash, Pending: true},
+		expiresAt: now.Add(ttl),
+	}
+
+	return nil, true, nil
+}
+
+func (c *MemoryCache) Complete(ctx context.Context, key string, rec *IdempotencyRecord, ttl time.Duration) error {
+	c.idempotency.mu.Lock()
+	defer c.idempotency.mu.Unlock()
+
+	c.idempotency.records[key] = memoryIdempotencyRecord{rec: *rec, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (c *MemoryCache) Release(ctx context.Context, key string) error {
+	c.idempotency.mu.Lock()
+	defer c.idempotency.mu.Unlock()
+
+	delete(c.idempotency.records, key)
+	return nil
+}
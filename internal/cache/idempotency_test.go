@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/config"
+)
+
+func TestMemoryCacheIdempotency(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(config.Cache{})
+
+	if _, reserved, _ := c.Reserve(ctx, "key", "hash", time.Minute); !reserved {
+		t.Fatal("expected the first request to reserve the key")
+	}
+
+	rec, reserved, _ := c.Reserve(ctx, "key", "hash", time.Minute)
+	if reserved || rec == nil || !rec.Pending {
+		t.Fatalf("expected a pending record for a repeated request, got %+v, reserved %t", rec, reserved)
+	}
+
+	// a reservation that was never completed expires
+	c.idempotency.records["key"] = memoryIdempotencyRecord{rec: *rec, expiresAt: time.Now().Add(-time.Second)}
+	if _, reserved, _ := c.Reserve(ctx, "key", "hash", time.Minute); !reserved {
+		t.Fatal("expected an expired reservation to be taken over")
+	}
+
+	if err := c.Complete(ctx, "key", &IdempotencyRecord{RequestHash: "hash", Status: 201}, time.Hour); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	if got := c.idempotency.records["key"].expiresAt; time.Until(got) < 59*time.Minute {
+		t.Errorf("expected the completed record to be kept for the ttl, expires in %s", time.Until(got))
+	}
+
+	// expired records are dropped on the next sweep
+	c.idempotency.records["stale"] = memoryIdempotencyRecord{expiresAt: time.Now().Add(-time.Second)}
+	c.idempotency.lastSweep = time.Time{}
+	c.Reserve(ctx, "other", "hash", time.Minute)
+
+	if _, ok := c.idempotency.records["stale"]; ok {
+		t.Error("expected the expired record to be swept")
+	}
+
+	if _, ok := c.idempotency.records["key"]; !ok {
+		t.Error("expected the completed record to be kept")
+	}
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/realdanielursul/order-service/pkg/retry"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	invalidationPublishTimeout = time.Second * 2
+	resubscribeBackoff         = time.Second
+	maxResubscribeBackoff      = time.Second * 30
+)
+
+// invalidation is published over redis pub/sub whenever a cached order is
+// written or deleted, so that other instances evict it from their local tier.
+type invalidation struct {
+	Key    string `json:"key"`
+	Origin string `json:"origin"`
+}
+
+// Invalidate tells every instance to drop key from its local tier. It is a
+// no-op when no invalidation channel is configured.
+func (c *Cache) Invalidate(ctx context.Context, key string) (err error) {
+	if c.channel == "" {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "cache.Invalidate", attribute.String("cache.key", key))
+	defer func() { endSpan(span, err) }()
+
+	payload, err := json.Marshal(invalidation{Key: key, Origin: c.instanceID})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, invalidationPublishTimeout)
+	defer cancel()
+
+	return c.redisErr(c.Publish(ctx, c.channel, payload).Err())
+}
+
+// RunInvalidation subscribes to the invalidation channel and evicts received
+// keys from the local tier until ctx is done. Whenever the subscription is
+// re-established events may have been missed, so the local tier is purged;
+// it is also purged every resync interval as a fallback.
+func (c *Cache) RunInvalidation(ctx context.Context) {
+	if c.channel == "" || c.local == nil {
+		return
+	}
+
+	pubsub := c.Subscribe(ctx, c.channel)
+	defer pubsub.Close()
+
+	var resync <-chan time.Time
+	if c.resyncInterval > 0 {
+		ticker := time.NewTicker(c.resyncInterval)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+
+	msgs := make(chan any)
+	go c.receive(ctx, pubsub, msgs)
+
+	subscribed := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resync:
+			c.local.Purge()
+			c.observeLocal()
+			logrus.Debug("local cache tier purged by periodic resync")
+		case msg := <-msgs:
+			switch msg := msg.(type) {
+			case *redis.Subscription:
+				if subscribed {
+					c.local.Purge()
+					c.observeLocal()
+					logrus.Infof("resubscribed to %q, local cache tier purged", c.channel)
+				}
+				subscribed = true
+			case *redis.Message:
+				var event invalidation
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					logrus.Warnf("malformed cache invalidation event: %v", err)
+					continue
+				}
+
+				if event.Origin != c.instanceID {
+					c.local.Delete(event.Key)
+					c.observeLocal()
+				}
+			}
+		}
+	}
+}
+
+// receive forwards pub/sub messages to msgs. The client reconnects on its
+// own; receive only backs off between failed attempts.
+func (c *Cache) receive(ctx context.Context, pubsub *redis.PubSub, msgs chan<- any) {
+	backoff := resubscribeBackoff
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			logrus.Warnf("cache invalidation subscription error, retrying in %s: %v", backoff, err)
+			if retry.Sleep(ctx, backoff) != nil {
+				return
+			}
+
+			backoff = min(backoff*2, maxResubscribeBackoff)
+			continue
+		}
+
+		backoff = resubscribeBackoff
+
+		select {
+		case msgs <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process cache bounded by entry count and total value size.
+// Expired entries are not returned by Get but are kept until evicted, so they
+// can still be served by GetStale while Redis is unavailable.
+type LRU struct {
+	mu         sync.Mutex
+	ll         *list.List
+	items      map[string]*list.Element
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	now        func() time.Time
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRU creates an LRU holding at most maxEntries entries and maxBytes bytes
+// of keys and values. A zero limit is not enforced.
+func NewLRU(maxEntries int, maxBytes int64) *LRU {
+	return &LRU{
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+	}
+}
+
+func (l *LRU) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*lruEntry)
+	if l.now().After(e.expiresAt) {
+		return nil, false
+	}
+
+	l.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// GetStale returns the value for key even if it has expired.
+func (l *LRU) GetStale(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	return el.Value.(*lruEntry).value, true
+}
+
+func (l *LRU) Set(key string, value []byte, ttl time.Duration) {
+	size := entrySize(key, value)
+	if l.maxBytes > 0 && size > l.maxBytes {
+		l.Delete(key)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		l.bytes += size - entrySize(e.key, e.value)
+		e.value, e.expiresAt = value, l.now().Add(ttl)
+		l.ll.MoveToFront(el)
+	} else {
+		l.items[key] = l.ll.PushFront(&lruEntry{key: key, value: value, expiresAt: l.now().Add(ttl)})
+		l.bytes += size
+	}
+
+	for (l.maxEntries > 0 && l.ll.Len() > l.maxEntries) || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+// Purge removes all entries.
+func (l *LRU) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+	l.bytes = 0
+}
+
+// Len returns the number of entries and their total size in bytes.
+func (l *LRU) Len() (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ll.Len(), l.bytes
+}
+
+func (l *LRU) removeElement(el *list.Element) {
+	e := l.ll.Remove(el).(*lruEntry)
+	delete(l.items, e.key)
+	l.bytes -= entrySize(e.key, e.value)
+}
+
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	const ttl = time.Minute
+
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int64
+		ops        func(l *LRU, advance func(time.Duration))
+		keys       []string // most recently used first
+		bytes      int64
+		expired    []string // kept for GetStale but missed by Get
+	}{
+		{
+			name:       "set and overwrite",
+			maxEntries: 10,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("22"), ttl)
+				l.Set("a", []byte("333"), ttl)
+			},
+			keys:  []string{"a", "b"},
+			bytes: 4 + 3,
+		},
+		{
+			name:       "evicts least recently set by entry count",
+			maxEntries: 2,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), ttl)
+				l.Set("c", []byte("3"), ttl)
+			},
+			keys:  []string{"c", "b"},
+			bytes: 4,
+		},
+		{
+			name:       "get protects from eviction",
+			maxEntries: 2,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), ttl)
+				l.Get("a")
+				l.Set("c", []byte("3"), ttl)
+			},
+			keys:  []string{"c", "a"},
+			bytes: 4,
+		},
+		{
+			name:       "get stale does not protect from eviction",
+			maxEntries: 2,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), ttl)
+				l.GetStale("a")
+				l.Set("c", []byte("3"), ttl)
+			},
+			keys:  []string{"c", "b"},
+			bytes: 4,
+		},
+		{
+			name:     "evicts by bytes",
+			maxBytes: 10,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1111"), ttl)
+				l.Set("b", []byte("2222"), ttl)
+				l.Set("c", []byte("33"), ttl)
+			},
+			keys:  []string{"c", "b"},
+			bytes: 3 + 5,
+		},
+		{
+			name:     "growing an entry evicts others",
+			maxBytes: 10,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), ttl)
+				l.Set("a", []byte("11111111"), ttl)
+			},
+			keys:  []string{"a"},
+			bytes: 9,
+		},
+		{
+			name:     "entry larger than max_bytes is dropped",
+			maxBytes: 5,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), ttl)
+				l.Set("a", []byte("11111"), ttl)
+			},
+			keys:  []string{"b"},
+			bytes: 2,
+		},
+		{
+			name: "no limits",
+			ops: func(l *LRU, _ func(time.Duration)) {
+				for _, key := range []string{"a", "b", "c", "d"} {
+					l.Set(key, []byte("value"), ttl)
+				}
+			},
+			keys:  []string{"d", "c", "b", "a"},
+			bytes: 4 * 6,
+		},
+		{
+			name:       "expired entries are kept for get stale",
+			maxEntries: 10,
+			ops: func(l *LRU, advance func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Set("b", []byte("2"), 2*ttl)
+				advance(ttl + time.Second)
+			},
+			keys:    []string{"b", "a"},
+			bytes:   4,
+			expired: []string{"a"},
+		},
+		{
+			name:       "overwrite refreshes ttl",
+			maxEntries: 10,
+			ops: func(l *LRU, advance func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				advance(ttl / 2)
+				l.Set("a", []byte("2"), ttl)
+				advance(ttl / 2)
+			},
+			keys:  []string{"a"},
+			bytes: 2,
+		},
+		{
+			name:       "delete and purge",
+			maxEntries: 10,
+			ops: func(l *LRU, _ func(time.Duration)) {
+				l.Set("a", []byte("1"), ttl)
+				l.Purge()
+				l.Set("b", []byte("2"), ttl)
+				l.Set("c", []byte("3"), ttl)
+				l.Delete("b")
+				l.Delete("missing")
+			},
+			keys:  []string{"c"},
+			bytes: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			l := NewLRU(tt.maxEntries, tt.maxBytes)
+			l.now = func() time.Time { return now }
+
+			tt.ops(l, func(d time.Duration) { now = now.Add(d) })
+
+			var keys []string
+			for el := l.ll.Front(); el != nil; el = el.Next() {
+				keys = append(keys, el.Value.(*lruEntry).key)
+			}
+			if !slices.Equal(keys, tt.keys) {
+				t.Errorf("expected keys %v, got %v", tt.keys, keys)
+			}
+
+			if entries, bytes := l.Len(); entries != len(tt.keys) || bytes != tt.bytes {
+				t.Errorf("expected %d entries and %d bytes, got %d and %d", len(tt.keys), tt.bytes, entries, bytes)
+			}
+
+			for _, key := range tt.keys {
+				if _, ok := l.GetStale(key); !ok {
+					t.Errorf("GetStale(%q): expected a value", key)
+				}
+
+				_, ok := l.Get(key)
+				if expired := slices.Contains(tt.expired, key); ok == expired {
+					t.Errorf("Get(%q): expected found %t, got %t", key, !expired, ok)
+				}
+			}
+		})
+	}
+}
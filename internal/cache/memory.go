@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/errs"
+)
+
+var errMiss = errors.New("cache miss")
+
+// MemoryCache is an in-process replacement for the Redis-backed Cache used in
+// standalone mode and tests. It is bounded by the local tier limits from the
+// cache config and uses the Redis TTL for its entries.
+type MemoryCache struct {
+	lru *LRU
+	ttl time.Duration
+
+	idempotency memoryIdempotency
+}
+
+func NewMemoryCache(cfg config.Cache) *MemoryCache {
+	ttl := cfg.RedisTTL
+	if ttl <= 0 {
+		ttl = defaultRedisTTL
+	}
+
+	return &MemoryCache{
+		lru:         NewLRU(cfg.LocalMaxEntries, cfg.LocalMaxBytes),
+		ttl:         ttl,
+		idempotency: memoryIdempotency{records: make(map[string]memoryIdempotencyRecord)},
+	}
+}
+
+func (c *MemoryCache) SetData(ctx context.Context, key string, value []byte) error {
+	c.lru.Set(key, value, c.ttl)
+	return nil
+}
+
+func (c *MemoryCache) SetMany(ctx context.Context, entries map[string][]byte) error {
+	for key, value := range entries {
+		c.lru.Set(key, value, c.ttl)
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) GetData(ctx context.Context, key string) ([]byte, error) {
+	value, ok := c.lru.Get(key)
+	if !ok {
+		return nil, errs.Wrap(errs.ErrNotFound, errMiss)
+	}
+
+	return decodeValue(value)
+}
+
+func (c *MemoryCache) SetMissing(ctx context.Context, key string, ttl time.Duration) error {
+	c.lru.Set(key, negativeValue, ttl)
+	return nil
+}
+
+// Invalidate is a no-op: a memory cache is never shared between instances.
+func (c *MemoryCache) Invalidate(ctx context.Context, key string) error {
+	return nil
+}
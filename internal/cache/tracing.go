@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/realdanielursul/order-service/internal/cache")
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, treating a miss as a regular outcome rather than a
+// failure.
+func endSpan(span trace.Span, err error) {
+	if errors.Is(err, errs.ErrNotFound) {
+		err = nil
+	}
+
+	tracing.End(span, err)
+}
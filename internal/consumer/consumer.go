@@ -3,33 +3,379 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
 
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/audit"
 	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/metrics"
 	"github.com/realdanielursul/order-service/internal/service"
+	"github.com/realdanielursul/order-service/pkg/logger"
+	"github.com/realdanielursul/order-service/pkg/retry"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func StartConsumer(service *service.Service, reader *kafka.Reader) {
+// handlerFunc processes one message. On failure it returns the stage that
+// failed, which ends up in the dead-letter headers.
+type handlerFunc func(ctx context.Context, m kafka.Message) (stage string, err error)
+
+// batchFunc processes a micro-batch of messages and reports which of them it
+// handled. The others go through the handlerFunc one by one, with its
+// retries and dead-lettering.
+type batchFunc func(ctx context.Context, batch []kafka.Message) (handled []bool)
+
+// Consumer is a running consumer loop.
+type Consumer struct {
+	reader    *kafka.Reader
+	stopWork  context.CancelFunc
+	fetchDone chan struct{}
+	workers   *pool
+
+	// time of the last processed batch, or of the start, in Unix nanoseconds
+	progress atomic.Int64
+}
+
+// StartConsumer consumes orders from the orders topic until ctx is done.
+func StartConsumer(ctx context.Context, service *service.Service, reader *kafka.Reader, dlq *kafka.Writer, cfg config.Kafka) *Consumer {
+	policy := retryPolicy(cfg)
+
+	handle := func(ctx context.Context, m kafka.Message) (string, error) {
+		return handleOrder(ctx, service, policy, m)
+	}
+
+	var batch batchFunc
+	if cfg.BatchSize > 1 {
+		batch = func(ctx context.Context, ms []kafka.Message) []bool {
+			return handleOrderBatch(ctx, service, ms)
+		}
+	}
+
+	return startLoop(ctx, reader, dlq, cfg, policy, handle, batch)
+}
+
+// StartStatusConsumer consumes status updates from the status topic until ctx
+// is done.
+func StartStatusConsumer(ctx context.Context, service *service.Service, reader *kafka.Reader, dlq *kafka.Writer, cfg config.Kafka) *Consumer {
+	policy := retryPolicy(cfg)
+
+	return startLoop(ctx, reader, dlq, cfg, policy, func(ctx context.Context, m kafka.Message) (string, error) {
+		return handleStatus(ctx, service, policy, cfg.StatusWaitTimeout, m)
+	}, nil)
+}
+
+func retryPolicy(cfg config.Kafka) retry.Policy {
+	return retry.Policy{
+		MaxAttempts: cfg.MaxRetries,
+		Initial:     cfg.RetryBackoff,
+		Max:         cfg.MaxRetryBackoff,
+	}
+}
+
+// startLoop consumes reader on a worker pool until ctx is done. Messages are
+// processed one by one by handle, or in micro-batches by batch when it is
+// set. Processing is detached from ctx so that a shutdown lets in-flight
+// messages finish; Stop bounds how long that may take.
+func startLoop(ctx context.Context, reader *kafka.Reader, dlq *kafka.Writer, cfg config.Kafka, policy retry.Policy, handle handlerFunc, batch batchFunc) *Consumer {
+	workCtx, stopWork := context.WithCancel(context.WithoutCancel(ctx))
+
+	batchSize := cfg.BatchSize
+	if batch == nil {
+		batchSize = 1
+	}
+
+	c := &Consumer{
+		reader:    reader,
+		stopWork:  stopWork,
+		fetchDone: make(chan struct{}),
+		workers:   newPool(cfg.Workers, cfg.WorkerQueueSize, batchSize, cfg.BatchTimeout),
+	}
+	c.progress.Store(time.Now().UnixNano())
+
+	// the offset is committed only once the message has either been
+	// processed or handed over to the dead-letter topic
+	c.workers.start(workCtx, reader, func(ctx context.Context, ms []kafka.Message) []error {
+		failures := make([]error, len(ms))
+
+		var handled []bool
+		if len(ms) > 1 {
+			handled = batch(ctx, ms)
+		}
+
+		for i, m := range ms {
+			if handled == nil || !handled[i] {
+				failures[i] = processMessage(ctx, dlq, policy, m, handle)
+			}
+		}
+
+		c.progress.Store(time.Now().UnixNano())
+		return failures
+	})
+
 	go func() {
+		defer close(c.fetchDone)
+		defer c.workers.close()
+
 		for {
-			m, err := reader.ReadMessage(context.Background())
+			m, err := reader.FetchMessage(ctx)
 			if err != nil {
-				logrus.Printf("kafka read error: %v\n", err)
-				continue
-			}
+				if ctx.Err() != nil {
+					return
+				}
 
-			var order entity.Order
-			if err := json.Unmarshal(m.Value, &order); err != nil {
-				logrus.Printf("invalid message: %v\n", err)
+				log := logrus.WithField(logger.FieldKafkaTopic, reader.Config().Topic)
+				if errors.Is(err, io.EOF) {
+					log.Info("kafka reader closed, consumer stopped")
+					return
+				}
+
+				log.Errorf("kafka fetch error: %v", err)
 				continue
 			}
 
-			if err := service.CreateOrder(context.Background(), &order); err != nil {
-				logrus.Printf("failed to save order: %v", err)
-			} else {
-				logrus.Printf("order saved: %s", order.OrderUID)
-			}
+			metrics.MessagesConsumed.WithLabelValues(m.Topic).Inc()
+			metrics.ObserveLag(m.Topic, m.Partition, m.Offset, m.HighWaterMark)
+
+			c.workers.dispatch(m)
 		}
 	}()
+
+	return c
+}
+
+// Stop waits until fetching has stopped, which happens once the context
+// passed to StartConsumer is done, and the fetched messages have been
+// processed and committed. If ctx ends first, in-flight processing is
+// cancelled and the unfinished messages are redelivered after a restart.
+// The reader is closed in both cases.
+func (c *Consumer) Stop(ctx context.Context) error {
+	select {
+	case <-c.fetchDone:
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-c.workers.done:
+	case <-ctx.Done():
+		logrus.Warnf("consumer did not drain in time, unfinished messages will be redelivered")
+	}
+
+	c.stopWork()
+
+	if err := c.reader.Close(); err != nil {
+		return fmt.Errorf("close kafka reader: %w", err)
+	}
+
+	return nil
+}
+
+// Check reports an error if the consumer lags more than maxLag messages
+// behind, or has lag but has not finished a message for staleAfter. Zero
+// values disable the respective check.
+func (c *Consumer) Check(maxLag int64, staleAfter time.Duration) error {
+	lag := c.reader.Stats().Lag
+
+	if maxLag > 0 && lag > maxLag {
+		return fmt.Errorf("consumer lag of %d messages exceeds %d", lag, maxLag)
+	}
+
+	idle := time.Since(time.Unix(0, c.progress.Load()))
+	if staleAfter > 0 && lag > 0 && idle > staleAfter {
+		return fmt.Errorf("consumer lags %d messages behind but made no progress for %s", lag, idle.Round(time.Second))
+	}
+
+	return nil
+}
+
+func processMessage(ctx context.Context, dlq *kafka.Writer, policy retry.Policy, m kafka.Message, handle handlerFunc) error {
+	ctx, span := startMessageSpan(ctx, m)
+	defer span.End()
+
+	ctx = audit.WithSource(ctx, audit.KafkaSource(m.Topic, m.Partition, m.Offset))
+	ctx = logger.WithKafkaMessage(ctx, m.Topic, m.Partition, m.Offset)
+
+	stage, err := handle(ctx, m)
+	if err == nil {
+		return nil
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String("messaging.failure_stage", stage))
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	metrics.MessagesFailed.WithLabelValues(m.Topic, stage).Inc()
+	return deadLetter(ctx, dlq, policy, m, stage, err)
+}
+
+func handleOrder(ctx context.Context, s *service.Service, policy retry.Policy, m kafka.Message) (string, error) {
+	var order entity.Order
+	if err := json.Unmarshal(m.Value, &order); err != nil {
+		logger.FromContext(ctx).Warnf("invalid message: %v", err)
+		return StageDecode, err
+	}
+
+	ctx = logger.WithOrderUID(ctx, order.OrderUID)
+	log := logger.FromContext(ctx)
+
+	var result entity.CreateResult
+	err := withRetry(ctx, policy, func(ctx context.Context) error {
+		var err error
+		result, err = s.CreateOrder(ctx, &order)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errs.ErrValidation) {
+			log.Warnf("invalid order %q: %v", order.OrderUID, err)
+			return StageValidate, err
+		}
+
+		log.Errorf("failed to save order: %v", err)
+		return StagePersist, err
+	}
+
+	log.Infof("order %s: %s", result, order.OrderUID)
+	return "", nil
+}
+
+func handleStatus(ctx context.Context, s *service.Service, policy retry.Policy, waitTimeout time.Duration, m kafka.Message) (string, error) {
+	var update entity.StatusUpdate
+	if err := json.Unmarshal(m.Value, &update); err != nil {
+		logger.FromContext(ctx).Warnf("invalid status message: %v", err)
+		return StageDecode, err
+	}
+
+	ctx = logger.WithOrderUID(ctx, update.OrderUID)
+	log := logger.FromContext(ctx)
+
+	// the status topic is not ordered with the orders topic, so an update
+	// may arrive before its order has been stored
+	err := waitForOrder(ctx, policy, waitTimeout, func(ctx context.Context) error {
+		return withRetry(ctx, policy, func(ctx context.Context) error {
+			_, err := s.UpdateStatus(ctx, update.OrderUID, update.Status)
+			return err
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrValidation):
+			log.Warnf("invalid status update for order %q: %v", update.OrderUID, err)
+			return StageValidate, err
+		case errors.Is(err, service.ErrIllegalTransition), errors.Is(err, errs.ErrNotFound):
+			log.Warnf("rejected status update for order %q: %v", update.OrderUID, err)
+			return StageTransition, err
+		}
+
+		log.Errorf("failed to update order status: %v", err)
+		return StagePersist, err
+	}
+
+	log.Infof("order %s status changed to %s", update.OrderUID, update.Status)
+	return "", nil
+}
+
+// handleOrderBatch stores the orders of a micro-batch with a single
+// CreateOrders call. Messages that cannot be decoded, orders that fail on
+// their own and the whole batch on a batch-wide error are left unhandled.
+func handleOrderBatch(ctx context.Context, s *service.Service, ms []kafka.Message) []bool {
+	ctx, span := startBatchSpan(ctx, ms)
+	defer span.End()
+
+	handled := make([]bool, len(ms))
+
+	var (
+		orders  []*entity.Order
+		indexes []int
+		sources = make(map[*entity.Order]string, len(ms))
+	)
+	for i, m := range ms {
+		var order entity.Order
+		if err := json.Unmarshal(m.Value, &order); err != nil {
+			continue
+		}
+
+		orders = append(orders, &order)
+		indexes = append(indexes, i)
+		sources[&order] = audit.KafkaSource(m.Topic, m.Partition, m.Offset)
+	}
+
+	if len(orders) == 0 {
+		return handled
+	}
+
+	results, err := s.CreateOrders(audit.WithOrderSources(ctx, sources), orders)
+	if err != nil {
+		span.RecordError(err)
+		logger.FromContext(ctx).Warnf("failed to save batch of %d orders, processing them one by one: %v", len(orders), err)
+		return handled
+	}
+
+	for j, res := range results {
+		if res.Err != nil {
+			continue
+		}
+
+		handled[indexes[j]] = true
+
+		m := ms[indexes[j]]
+		mctx := logger.WithOrderUID(logger.WithKafkaMessage(ctx, m.Topic, m.Partition, m.Offset), res.OrderUID)
+		logger.FromContext(mctx).Infof("order %s: %s", res.Result, res.OrderUID)
+	}
+
+	return handled
+}
+
+// withRetry retries fn on transient errors using the consumer retry policy.
+func withRetry(ctx context.Context, policy retry.Policy, fn func(ctx context.Context) error) error {
+	return retry.Do(ctx, policy, errs.IsRetryable, func(ctx context.Context) error {
+		err := fn(ctx)
+		if err != nil && errs.IsRetryable(err) {
+			logger.FromContext(ctx).Warnf("transient error processing order, retrying: %v", err)
+		}
+
+		return err
+	})
+}
+
+// waitForOrder retries fn while it fails with errs.ErrNotFound, until timeout
+// has passed.
+func waitForOrder(ctx context.Context, policy retry.Policy, timeout time.Duration, fn func(ctx context.Context) error) error {
+	deadline := time.Now().Add(timeout)
+	policy.MaxAttempts = 0
+
+	return retry.Do(ctx, policy, func(err error) bool {
+		return errors.Is(err, errs.ErrNotFound) && time.Now().Before(deadline)
+	}, func(ctx context.Context) error {
+		err := fn(ctx)
+		if errors.Is(err, errs.ErrNotFound) && time.Now().Before(deadline) {
+			logger.FromContext(ctx).Debugf("order not stored yet, waiting: %v", err)
+		}
+
+		return err
+	})
+}
+
+// deadLetter keeps retrying the dead-letter publish so that a failed message
+// is never committed without being stored somewhere.
+func deadLetter(ctx context.Context, dlq *kafka.Writer, policy retry.Policy, m kafka.Message, stage string, cause error) error {
+	policy.MaxAttempts = 0
+
+	return retry.Do(ctx, policy, func(error) bool { return true }, func(ctx context.Context) error {
+		err := sendToDLQ(ctx, dlq, m, stage, cause)
+		if err != nil {
+			logger.FromContext(ctx).Errorf("failed to send message (partition %d, offset %d) to dead-letter topic: %v", m.Partition, m.Offset, err)
+		}
+
+		return err
+	})
 }
@@ -0,0 +1,56 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/pkg/retry"
+)
+
+func TestWaitForOrder(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 1, Initial: time.Millisecond, Max: time.Millisecond}
+	notFound := fmt.Errorf("order %q: %w", "late", errs.ErrNotFound)
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		failures int // calls failing with errs.ErrNotFound before fn succeeds
+		err      error
+		calls    int
+	}{
+		{name: "found", timeout: time.Minute, calls: 1},
+		{name: "stored later", timeout: time.Minute, failures: 3, calls: 4},
+		{name: "no wait", failures: 3, err: errs.ErrNotFound, calls: 1},
+		{name: "other error", timeout: time.Minute, err: errs.ErrConflict, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := waitForOrder(context.Background(), policy, tt.timeout, func(context.Context) error {
+				calls++
+				switch {
+				case calls <= tt.failures:
+					return notFound
+				case tt.err != nil && tt.failures == 0:
+					return tt.err
+				}
+				return nil
+			})
+
+			if tt.err == nil && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+			if calls != tt.calls {
+				t.Errorf("expected %d calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package consumer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// failure stages reported in the dead-letter headers
+const (
+	StageDecode     = "decode"
+	StageValidate   = "validate"
+	StagePersist    = "persist"
+	StageTransition = "transition"
+)
+
+const (
+	headerOriginalTopic     = "x-original-topic"
+	headerOriginalPartition = "x-original-partition"
+	headerOriginalOffset    = "x-original-offset"
+	headerFailureStage      = "x-failure-stage"
+	headerError             = "x-error"
+	headerFailedAt          = "x-failed-at"
+)
+
+const dlqWriteTimeout = time.Second * 5
+
+// sendToDLQ republishes a message that could not be processed to the
+// dead-letter topic. Without a configured writer the message is only logged.
+func sendToDLQ(ctx context.Context, writer *kafka.Writer, m kafka.Message, stage string, cause error) error {
+	if writer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dlqWriteTimeout)
+	defer cancel()
+
+	headers := append([]kafka.Header{}, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: headerOriginalTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: headerOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: headerOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: headerFailureStage, Value: []byte(stage)},
+		kafka.Header{Key: headerError, Value: []byte(cause.Error())},
+		kafka.Header{Key: headerFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	return writer.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+}
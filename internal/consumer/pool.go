@@ -0,0 +1,289 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/realdanielursul/order-service/pkg/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultWorkerQueueSize = 64
+	defaultBatchTimeout    = 100 * time.Millisecond
+)
+
+// pool processes messages on a fixed set of workers. Messages with the same
+// key always go to the same worker, so they are processed in order, and a
+// full worker queue blocks fetching. Offsets are committed per partition
+// only up to the last message before the first one still in flight.
+//
+// With a batch size above one, each worker hands its messages over in
+// micro-batches that are closed once full or once the batch timeout has
+// passed since their first message.
+type pool struct {
+	queues  []chan kafka.Message
+	tracker *offsetTracker
+	commits chan kafka.Message
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	batchSize    int
+	batchTimeout time.Duration
+}
+
+func newPool(workers, queueSize, batchSize int, batchTimeout time.Duration) *pool {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if queueSize <= 0 {
+		queueSize = defaultWorkerQueueSize
+	}
+
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	if batchTimeout <= 0 {
+		batchTimeout = defaultBatchTimeout
+	}
+
+	p := &pool{
+		queues:       make([]chan kafka.Message, workers),
+		tracker:      newOffsetTracker(),
+		commits:      make(chan kafka.Message, workers*queueSize),
+		done:         make(chan struct{}),
+		batchSize:    batchSize,
+		batchTimeout: batchTimeout,
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan kafka.Message, queueSize)
+	}
+
+	return p
+}
+
+// start runs the workers and the committer. process returns one error per
+// message of the batch, set only if that message must stay uncommitted.
+// Offsets are committed even after ctx is done, so that messages finished
+// while draining are not redelivered.
+func (p *pool) start(ctx context.Context, reader *kafka.Reader, process func(ctx context.Context, batch []kafka.Message) []error) {
+	for _, queue := range p.queues {
+		p.workers.Add(1)
+
+		go func() {
+			defer p.workers.Done()
+
+			for {
+				batch := p.collect(queue)
+				if len(batch) == 0 {
+					return
+				}
+
+				for i, err := range process(ctx, batch) {
+					m := batch[i]
+					if err != nil {
+						logger.FromContext(logger.WithKafkaMessage(ctx, m.Topic, m.Partition, m.Offset)).Errorf("message (partition %d, offset %d) left uncommitted: %v", m.Partition, m.Offset, err)
+						continue
+					}
+
+					if commit, ok := p.tracker.done(m); ok {
+						p.commits <- commit
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		p.workers.Wait()
+		close(p.commits)
+	}()
+
+	go p.commitLoop(context.WithoutCancel(ctx), reader)
+}
+
+// close stops accepting messages. The workers finish the queued ones, and
+// done is closed once their offsets have been committed.
+func (p *pool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+}
+
+// collect waits for the next message of queue and adds the messages that
+// follow it until the batch is full or the batch timeout passes. It returns
+// nothing once queue is closed and drained.
+func (p *pool) collect(queue <-chan kafka.Message) []kafka.Message {
+	m, ok := <-queue
+	if !ok {
+		return nil
+	}
+
+	batch := []kafka.Message{m}
+	if p.batchSize == 1 {
+		return batch
+	}
+
+	timer := time.NewTimer(p.batchTimeout)
+	defer timer.Stop()
+
+	for len(batch) < p.batchSize {
+		select {
+		case m, ok := <-queue:
+			if !ok {
+				return batch
+			}
+
+			batch = append(batch, m)
+		case <-timer.C:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+// dispatch hands m to the worker owning its key, blocking while that
+// worker's queue is full.
+func (p *pool) dispatch(m kafka.Message) {
+	p.tracker.track(m)
+
+	h := fnv.New32a()
+	h.Write([]byte(routingKey(m)))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- m
+}
+
+// commitLoop commits offsets, never moving a partition back. Every commit is
+// a broker round-trip, so all offsets that became ready in the meantime are
+// committed together, one per partition.
+func (p *pool) commitLoop(ctx context.Context, reader *kafka.Reader) {
+	defer close(p.done)
+
+	committed := make(map[int]int64)
+
+	for m := range p.commits {
+		var batch []kafka.Message
+		for _, m := range p.drainCommits(m) {
+			if last, ok := committed[m.Partition]; ok && m.Offset <= last {
+				continue
+			}
+
+			batch = append(batch, m)
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := reader.CommitMessages(ctx, batch...); err != nil {
+			for _, m := range batch {
+				logger.FromContext(logger.WithKafkaMessage(ctx, m.Topic, m.Partition, m.Offset)).Errorf("failed to commit offset (partition %d, offset %d): %v", m.Partition, m.Offset, err)
+			}
+			continue
+		}
+
+		for _, m := range batch {
+			committed[m.Partition] = m.Offset
+		}
+	}
+}
+
+// drainCommits takes the commits already queued behind first without
+// waiting and returns the highest message of each partition among them.
+func (p *pool) drainCommits(first kafka.Message) map[int]kafka.Message {
+	latest := map[int]kafka.Message{first.Partition: first}
+
+	for {
+		select {
+		case m, ok := <-p.commits:
+			if !ok {
+				return latest
+			}
+
+			if prev, ok := latest[m.Partition]; !ok || m.Offset > prev.Offset {
+				latest[m.Partition] = m
+			}
+		default:
+			return latest
+		}
+	}
+}
+
+// routingKey is the message key, the order_uid of the payload, or the
+// partition if neither is present.
+func routingKey(m kafka.Message) string {
+	if len(m.Key) > 0 {
+		return string(m.Key)
+	}
+
+	var payload struct {
+		OrderUID string `json:"order_uid"`
+	}
+	if err := json.Unmarshal(m.Value, &payload); err == nil && payload.OrderUID != "" {
+		return payload.OrderUID
+	}
+
+	return "partition-" + strconv.Itoa(m.Partition)
+}
+
+// offsetTracker remembers the in-flight offsets of every partition in fetch
+// order.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	pending []kafka.Message
+	done    map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets)}
+}
+
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[m.Partition] = p
+	}
+
+	p.pending = append(p.pending, m)
+}
+
+// done marks m as processed and returns the highest message of its partition
+// that can now be committed, if any.
+func (t *offsetTracker) done(m kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		return kafka.Message{}, false
+	}
+
+	p.done[m.Offset] = true
+
+	var (
+		commit kafka.Message
+		found  bool
+	)
+	for len(p.pending) > 0 && p.done[p.pending[0].Offset] {
+		commit, found = p.pending[0], true
+		delete(p.done, commit.Offset)
+		p.pending = p.pending[1:]
+	}
+
+	return commit, found
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func msg(partition int, offset int64) kafka.Message {
+	return kafka.Message{Partition: partition, Offset: offset}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	type step struct {
+		done   kafka.Message
+		commit int64 // offset expected to become committable, -1 for none
+	}
+
+	tests := []struct {
+		name  string
+		track []kafka.Message
+		steps []step
+	}{
+		{
+			name:  "in order",
+			track: []kafka.Message{msg(0, 10), msg(0, 11), msg(0, 12)},
+			steps: []step{{msg(0, 10), 10}, {msg(0, 11), 11}, {msg(0, 12), 12}},
+		},
+		{
+			name:  "later message finished first",
+			track: []kafka.Message{msg(0, 10), msg(0, 11), msg(0, 12)},
+			steps: []step{{msg(0, 12), -1}, {msg(0, 11), -1}, {msg(0, 10), 12}},
+		},
+		{
+			name:  "gap in the middle",
+			track: []kafka.Message{msg(0, 10), msg(0, 11), msg(0, 12)},
+			steps: []step{{msg(0, 10), 10}, {msg(0, 12), -1}, {msg(0, 11), 12}},
+		},
+		{
+			name:  "offsets not contiguous",
+			track: []kafka.Message{msg(0, 10), msg(0, 15), msg(0, 40)},
+			steps: []step{{msg(0, 15), -1}, {msg(0, 10), 15}, {msg(0, 40), 40}},
+		},
+		{
+			name:  "partitions are independent",
+			track: []kafka.Message{msg(0, 10), msg(1, 10), msg(0, 11), msg(1, 11)},
+			steps: []step{{msg(1, 11), -1}, {msg(0, 10), 10}, {msg(1, 10), 11}, {msg(0, 11), 11}},
+		},
+		{
+			name:  "untracked partition",
+			track: []kafka.Message{msg(0, 10)},
+			steps: []step{{msg(1, 10), -1}, {msg(0, 10), 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker()
+			for _, m := range tt.track {
+				tracker.track(m)
+			}
+
+			for i, s := range tt.steps {
+				commit, ok := tracker.done(s.done)
+
+				switch {
+				case s.commit < 0 && ok:
+					t.Errorf("step %d: expected nothing to commit, got partition %d offset %d", i, commit.Partition, commit.Offset)
+				case s.commit >= 0 && !ok:
+					t.Errorf("step %d: expected offset %d to commit, got nothing", i, s.commit)
+				case s.commit >= 0 && (commit.Offset != s.commit || commit.Partition != s.done.Partition):
+					t.Errorf("step %d: expected partition %d offset %d, got partition %d offset %d", i, s.done.Partition, s.commit, commit.Partition, commit.Offset)
+				}
+			}
+
+			for partition, p := range tracker.partitions {
+				if len(p.pending) > 0 || len(p.done) > 0 {
+					t.Errorf("partition %d: expected nothing left in flight, got %d pending and %d done", partition, len(p.pending), len(p.done))
+				}
+			}
+		})
+	}
+}
+
+func TestDrainCommits(t *testing.T) {
+	p := newPool(1, 8, 1, 0)
+
+	for _, m := range []kafka.Message{msg(0, 11), msg(1, 5), msg(0, 13), msg(1, 4)} {
+		p.commits <- m
+	}
+
+	latest := p.drainCommits(msg(0, 10))
+	if len(latest) != 2 || latest[0].Offset != 13 || latest[1].Offset != 5 {
+		t.Errorf("expected offsets 13 and 5 for partitions 0 and 1, got %v", latest)
+	}
+
+	if n := len(p.commits); n != 0 {
+		t.Errorf("expected the commit queue to be drained, %d left", n)
+	}
+}
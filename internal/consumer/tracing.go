@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/realdanielursul/order-service/pkg/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/realdanielursul/order-service/internal/consumer")
+
+// startMessageSpan starts the processing span of m as a child of the trace
+// context found in its headers, if any.
+func startMessageSpan(ctx context.Context, m kafka.Message) (context.Context, trace.Span) {
+	ctx = tracing.ExtractKafka(ctx, m)
+
+	return tracer.Start(ctx, "process "+m.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(m.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(m.Partition)),
+			semconv.MessagingKafkaOffset(int(m.Offset)),
+			semconv.MessagingKafkaMessageKey(string(m.Key)),
+		),
+	)
+}
+
+// startBatchSpan starts the span of a micro-batch. A batch has no single
+// parent, so the span is linked to the trace of every message instead.
+func startBatchSpan(ctx context.Context, ms []kafka.Message) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(ms))
+	for _, m := range ms {
+		if sc := trace.SpanContextFromContext(tracing.ExtractKafka(ctx, m)); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+
+	return tracer.Start(ctx, "process batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			attribute.Int("messaging.batch.message_count", len(ms)),
+		),
+	)
+}
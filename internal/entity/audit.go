@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate       AuditAction = "create"
+	AuditActionUpdate       AuditAction = "update"
+	AuditActionStatusChange AuditAction = "status_change"
+)
+
+// AuditEntry is one write to an order with snapshots of the order before and
+// after it. Before is empty for creates.
+type AuditEntry struct {
+	ID        int64           `json:"id" db:"id"`
+	OrderUID  string          `json:"order_uid" db:"order_uid"`
+	Action    AuditAction     `json:"action" db:"action"`
+	Before    json.RawMessage `json:"before,omitempty" db:"before"`
+	After     json.RawMessage `json:"after,omitempty" db:"after"`
+	Source    string          `json:"source" db:"source"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const EventOrderAccepted = "order.accepted"
+
+// OrderEvent is published to downstream consumers through the outbox.
+type OrderEvent struct {
+	Type       string       `json:"type"`
+	OrderUID   string       `json:"order_uid"`
+	Result     CreateResult `json:"result"`
+	Order      *Order       `json:"order"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// OutboxMessage is a pending event stored in the same transaction as the
+// write that produced it.
+type OutboxMessage struct {
+	ID        int64           `db:"id"`
+	EventType string          `db:"event_type"`
+	Key       string          `db:"key"`
+	Payload   json.RawMessage `db:"payload"`
+	Attempts  int             `db:"attempts"`
+	CreatedAt time.Time       `db:"created_at"`
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// CreateResult describes what happened to an order handed to CreateOrder.
+type CreateResult string
+
+const (
+	CreateResultCreated   CreateResult = "created"   // new order stored
+	CreateResultDuplicate CreateResult = "duplicate" // identical order already stored, nothing changed
+	CreateResultUpdated   CreateResult = "updated"   // stored order replaced by the new content
+	CreateResultConflict  CreateResult = "conflict"  // different content for a stored order, recorded in the conflict log
+)
+
+// BatchResult is the outcome of one order handed to CreateOrders. Err is set
+// when that order alone could not be stored.
+type BatchResult struct {
+	OrderUID string
+	Result   CreateResult
+	Err      error
+}
+
+// ConflictPolicy decides how an order with a known order_uid but different
+// content is handled.
+type ConflictPolicy string
+
+const (
+	ConflictPolicyReject ConflictPolicy = "reject"
+	ConflictPolicyUpdate ConflictPolicy = "update"
+)
+
+// ContentHash returns a digest of the order content used to tell redelivered
+// duplicates apart from conflicting writes. The status is not part of the
+// content since it changes over the order lifecycle.
+func (o *Order) ContentHash() (string, error) {
+	content := *o
+	content.Status = ""
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
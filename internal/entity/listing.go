@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// OrderFilter selects orders for a listing page. Empty fields are ignored.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	Currency        string
+	Provider        string
+	Bank            string
+	Brand           string
+	CreatedFrom     time.Time // inclusive
+	CreatedTo       time.Time // exclusive
+	After           *OrderCursor
+	Limit           int
+}
+
+// OrderCursor points at the last order of a page. Orders are listed newest
+// first, ordered by (date_created, order_uid).
+type OrderCursor struct {
+	DateCreated time.Time `json:"d"`
+	OrderUID    string    `json:"u"`
+}
+
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+func (c OrderCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeOrderCursor(s string) (*OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var c OrderCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.OrderUID == "" {
+		return nil, errors.New("malformed cursor")
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+type OrderStatus string
+
+const (
+	OrderStatusCreated    OrderStatus = "created"
+	OrderStatusPaid       OrderStatus = "paid"
+	OrderStatusAssembling OrderStatus = "assembling"
+	OrderStatusShipped    OrderStatus = "shipped"
+	OrderStatusDelivered  OrderStatus = "delivered"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusReturned   OrderStatus = "returned"
+)
+
+// StatusUpdate is the payload of the order status topic.
+type StatusUpdate struct {
+	OrderUID string      `json:"order_uid"`
+	Status   OrderStatus `json:"status"`
+}
+
+// StatusChange is one entry of the order status history.
+type StatusChange struct {
+	OrderUID  string      `json:"order_uid" db:"order_uid"`
+	From      OrderStatus `json:"from,omitempty" db:"from_status"`
+	To        OrderStatus `json:"to" db:"to_status"`
+	Source    string      `json:"source" db:"source"`
+	ChangedAt time.Time   `json:"changed_at" db:"changed_at"`
+}
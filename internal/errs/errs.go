@@ -0,0 +1,47 @@
+package errs
+
+import "errors"
+
+// Domain errors shared by the repository, cache, service and handler layers.
+// Layers wrap them with context via fmt.Errorf("...: %w", err) and callers
+// check them with errors.Is.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrValidation  = errors.New("validation failed")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("dependency unavailable")
+	ErrTimeout     = errors.New("timeout")
+)
+
+// Error attaches a domain kind to an underlying cause so that both can be
+// matched with errors.Is and errors.As.
+type Error struct {
+	Kind  error
+	Cause error
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return e.Kind.Error()
+	}
+
+	return e.Kind.Error() + ": " + e.Cause.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Cause}
+}
+
+// Wrap marks cause as an error of the given kind. A nil cause stays nil.
+func Wrap(kind, cause error) error {
+	if cause == nil {
+		return nil
+	}
+
+	return &Error{Kind: kind, Cause: cause}
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrTimeout)
+}
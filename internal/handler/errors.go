@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/service"
+	"github.com/realdanielursul/order-service/pkg/logger"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details body.
+type problem struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Errors   []service.FieldError `json:"errors,omitempty"`
+}
+
+var problemTypes = []struct {
+	err    error
+	status int
+	typ    string
+}{
+	{errs.ErrNotFound, http.StatusNotFound, "/problems/not-found"},
+	{errs.ErrValidation, http.StatusUnprocessableEntity, "/problems/validation-failed"},
+	{errs.ErrConflict, http.StatusConflict, "/problems/conflict"},
+	{errs.ErrUnavailable, http.StatusServiceUnavailable, "/problems/dependency-unavailable"},
+	{errs.ErrTimeout, http.StatusGatewayTimeout, "/problems/timeout"},
+}
+
+// writeError maps err onto an HTTP status and writes it as problem+json.
+func writeError(c *gin.Context, err error) {
+	writeProblem(c, toProblem(c, err))
+}
+
+// toProblem maps err onto an HTTP status and problem details body.
+func toProblem(c *gin.Context, err error) problem {
+	p := problem{
+		Type:     "about:blank",
+		Status:   http.StatusInternalServerError,
+		Instance: c.Request.URL.Path,
+	}
+
+	for _, t := range problemTypes {
+		if errors.Is(err, t.err) {
+			p.Type, p.Status = t.typ, t.status
+			break
+		}
+	}
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		p.Errors = validationErr.Fields
+	}
+
+	p.Title = http.StatusText(p.Status)
+	if p.Status == http.StatusInternalServerError {
+		logger.FromContext(c.Request.Context()).Errorf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	} else {
+		p.Detail = err.Error()
+	}
+
+	return p
+}
+
+func writeProblem(c *gin.Context, p problem) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -2,45 +2,161 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
 	"github.com/realdanielursul/order-service/internal/service"
+	"github.com/realdanielursul/order-service/pkg/health"
 )
 
 type Handler struct {
-	services *service.Service
+	services    *service.Service
+	idempotency IdempotencyStore
+	readiness   *health.Checker
+
+	maxBodyBytes   int64
+	maxBatchSize   int
+	idempotencyTTL time.Duration
 }
 
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+func NewHandler(services *service.Service, idempotency IdempotencyStore, readiness *health.Checker, cfg config.HTTP) *Handler {
+	h := &Handler{
+		services:       services,
+		idempotency:    idempotency,
+		readiness:      readiness,
+		maxBodyBytes:   cfg.MaxBodyBytes,
+		maxBatchSize:   cfg.MaxBatchSize,
+		idempotencyTTL: cfg.IdempotencyTTL,
+	}
+
+	if h.maxBodyBytes <= 0 {
+		h.maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	if h.maxBatchSize <= 0 {
+		h.maxBatchSize = defaultMaxBatchSize
+	}
+
+	if h.idempotencyTTL <= 0 {
+		h.idempotencyTTL = defaultIdempotencyTTL
+	}
+
+	return h
 }
 
 func (h *Handler) InitRoutes() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), observeRequest, traceRequest, logRequest)
 
 	router.StaticFile("/", "./web/index.html")
 
-	router.GET("/order/:order_uid", func(c *gin.Context) {
-		orderUID := c.Param("order_uid")
-		if orderUID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"reason": "empty order_uid param"})
-			return
+	router.GET("/healthz", h.healthz)
+	router.GET("/readyz", h.readyz)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	router.Use(auditSource)
+
+	router.GET("/order/:order_uid", h.getOrder)
+	router.GET("/order/:order_uid/history", h.getOrderHistory)
+	router.GET("/orders", h.listOrders)
+	router.POST("/orders", h.createOrder)
+	router.POST("/orders/batch", h.createOrdersBatch)
+
+	return router
+}
+
+func (h *Handler) getOrder(c *gin.Context) {
+	orderUID := c.Param("order_uid")
+	if orderUID == "" {
+		writeProblem(c, problem{Status: http.StatusBadRequest, Detail: "empty order_uid param"})
+		return
+	}
+
+	order, err := h.services.GetOrder(c.Request.Context(), orderUID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) getOrderHistory(c *gin.Context) {
+	history, err := h.services.GetOrderHistory(c.Request.Context(), c.Param("order_uid"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *Handler) listOrders(c *gin.Context) {
+	filter := entity.OrderFilter{
+		CustomerID:      c.Query("customer_id"),
+		DeliveryService: c.Query("delivery_service"),
+		Locale:          c.Query("locale"),
+		Currency:        c.Query("currency"),
+		Provider:        c.Query("provider"),
+		Bank:            c.Query("bank"),
+		Brand:           c.Query("brand"),
+	}
+
+	var invalid []service.FieldError
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			invalid = append(invalid, service.FieldError{Field: "limit", Message: "must be a positive integer"})
 		}
+		filter.Limit = limit
+	}
 
-		order, err := h.services.GetOrder(c.Request.Context(), orderUID)
-		if err != nil {
-			if err.Error() == "order not found" {
-				c.JSON(http.StatusNotFound, gin.H{"reason": err.Error()})
-				return
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_from", &filter.CreatedFrom}, {"created_to", &filter.CreatedTo}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				invalid = append(invalid, service.FieldError{Field: p.name, Message: "must be an RFC 3339 timestamp"})
 			}
+			*p.dst = t.UTC()
+		}
+	}
 
-			c.JSON(http.StatusInternalServerError, gin.H{"reason": err.Error()})
-			return
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := entity.DecodeOrderCursor(v)
+		if err != nil {
+			invalid = append(invalid, service.FieldError{Field: "cursor", Message: err.Error()})
 		}
+		filter.After = cursor
+	}
 
-		c.JSON(http.StatusOK, order)
-	})
+	if len(invalid) > 0 {
+		writeError(c, &service.ValidationError{Fields: invalid})
+		return
+	}
 
-	return router
+	page, err := h.services.ListOrders(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// auditSource records the HTTP caller as the source of any write made while
+// serving the request.
+func auditSource(c *gin.Context) {
+	ctx := audit.WithSource(c.Request.Context(), audit.HTTPSource(c.ClientIP()))
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
 }
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthz reports that the process is up and serving requests.
+func (h *Handler) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz runs the dependency checks and reports each of them, with 503 if
+// any failed.
+func (h *Handler) readyz(c *gin.Context) {
+	report := h.readiness.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
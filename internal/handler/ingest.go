@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/realdanielursul/order-service/internal/cache"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/pkg/httpserver"
+	"github.com/realdanielursul/order-service/pkg/logger"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	ndjsonContentType         = "application/x-ndjson"
+	defaultMaxBodyBytes       = 10 << 20 // 10 MB
+	defaultMaxBatchSize       = 1000
+	defaultIdempotencyTTL     = 24 * time.Hour
+	maxIdempotencyKeyLength   = 255
+	idempotencyReleaseTimeout = 2 * time.Second
+
+	// idempotencyLockTTL is how long a pending reservation is kept. Requests
+	// are cut off at the write timeout, so the reservation outlives them and
+	// still expires soon if the instance dies before completing it.
+	idempotencyLockTTL = httpserver.WriteTimeout + idempotencyReleaseTimeout
+)
+
+// IdempotencyStore keeps the outcome of requests made with an
+// Idempotency-Key. cache.Cache and cache.MemoryCache implement it.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*cache.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key string, rec *cache.IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+// orderResult is the outcome of ingesting one order.
+type orderResult struct {
+	Index    *int                `json:"index,omitempty"`
+	OrderUID string              `json:"order_uid,omitempty"`
+	Result   entity.CreateResult `json:"result,omitempty"`
+	Status   int                 `json:"status"`
+	Error    *problem            `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []orderResult `json:"results"`
+}
+
+func (h *Handler) createOrder(c *gin.Context) {
+	h.idempotent(c, func(body []byte) (int, any) {
+		res := h.ingest(c, body)
+		if res.Error != nil {
+			return res.Status, res.Error
+		}
+
+		return res.Status, res
+	})
+}
+
+func (h *Handler) createOrdersBatch(c *gin.Context) {
+	h.idempotent(c, func(body []byte) (int, any) {
+		mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+		var (
+			docs []json.RawMessage
+			err  error
+		)
+		if mediaType == ndjsonContentType {
+			docs, err = splitNDJSON(body)
+		} else {
+			err = json.Unmarshal(body, &docs)
+		}
+		if err != nil {
+			return http.StatusBadRequest, badRequest(c, fmt.Sprintf("malformed batch: %v", err))
+		}
+
+		if len(docs) == 0 {
+			return http.StatusBadRequest, badRequest(c, "empty batch")
+		}
+
+		if len(docs) > h.maxBatchSize {
+			return http.StatusRequestEntityTooLarge, &problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(http.StatusRequestEntityTooLarge),
+				Status:   http.StatusRequestEntityTooLarge,
+				Detail:   fmt.Sprintf("batch of %d orders exceeds the limit of %d", len(docs), h.maxBatchSize),
+				Instance: c.Request.URL.Path,
+			}
+		}
+
+		resp := batchResponse{Results: make([]orderResult, 0, len(docs))}
+		for i, doc := range docs {
+			res := h.ingest(c, doc)
+			res.Index = &i
+			resp.Results = append(resp.Results, res)
+		}
+
+		return http.StatusOK, resp
+	})
+}
+
+// ingest decodes and stores one order through the same path as the Kafka
+// consumer.
+func (h *Handler) ingest(c *gin.Context, doc []byte) orderResult {
+	var order entity.Order
+	if err := json.Unmarshal(doc, &order); err != nil {
+		p := badRequest(c, fmt.Sprintf("malformed order: %v", err))
+		return orderResult{Status: p.Status, Error: p}
+	}
+
+	result, err := h.services.CreateOrder(c.Request.Context(), &order)
+	if err != nil {
+		p := toProblem(c, err)
+		return orderResult{OrderUID: order.OrderUID, Status: p.Status, Error: &p}
+	}
+
+	res := orderResult{OrderUID: order.OrderUID, Result: result}
+	switch result {
+	case entity.CreateResultCreated:
+		res.Status = http.StatusCreated
+	case entity.CreateResultConflict:
+		res.Status = http.StatusConflict
+	default:
+		res.Status = http.StatusOK
+	}
+
+	return res
+}
+
+// idempotent reads the request body and runs fn. With an Idempotency-Key
+// header the outcome is stored, a repeated request with the same key and
+// body gets the stored response replayed, and a request reusing the key with
+// a different body is rejected. The key is reserved only for as long as the
+// request may run, and the outcome is kept for the idempotency TTL. Server
+// errors and panics, including a batch in which any
+// order failed with one, are not stored so that the client may retry; orders
+// of the batch that were stored come back as duplicates on the retry.
+func (h *Handler) idempotent(c *gin.Context, fn func(body []byte) (int, any)) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeProblem(c, problem{Status: http.StatusRequestEntityTooLarge, Detail: fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit)})
+			return
+		}
+
+		writeProblem(c, problem{Status: http.StatusBadRequest, Detail: "failed to read request body"})
+		return
+	}
+
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		status, resp := fn(body)
+		respond(c, status, resp)
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		writeProblem(c, problem{Status: http.StatusBadRequest, Detail: fmt.Sprintf("%s must not exceed %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)})
+		return
+	}
+
+	sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), body...))
+	hash := hex.EncodeToString(sum[:])
+
+	ctx := c.Request.Context()
+	rec, reserved, err := h.idempotency.Reserve(ctx, key, hash, idempotencyLockTTL)
+	if err != nil {
+		writeError(c, fmt.Errorf("reserve idempotency key: %w", err))
+		return
+	}
+
+	if !reserved {
+		switch {
+		case rec.RequestHash != hash:
+			writeProblem(c, problem{Status: http.StatusUnprocessableEntity, Detail: idempotencyKeyHeader + " was already used for a different request"})
+		case rec.Pending:
+			writeProblem(c, problem{Status: http.StatusConflict, Detail: "a request with this " + idempotencyKeyHeader + " is still being processed"})
+		default:
+			contentType := "application/json; charset=utf-8"
+			if rec.Status >= http.StatusBadRequest {
+				contentType = problemContentType
+			}
+
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(rec.Status, contentType, rec.Body)
+		}
+
+		return
+	}
+
+	// a panicking request is answered by the recovery middleware with a
+	// server error, which lets the client retry as well
+	defer func() {
+		if p := recover(); p != nil {
+			h.releaseIdempotencyKey(ctx, key)
+			panic(p)
+		}
+	}()
+
+	// stop processing before the reservation expires, the response could
+	// not be written after the write timeout anyway
+	fnCtx, cancel := context.WithTimeout(ctx, httpserver.WriteTimeout)
+	defer cancel()
+	c.Request = c.Request.WithContext(fnCtx)
+
+	status, resp := fn(body)
+
+	data, err := json.Marshal(resp)
+	if err != nil || isServerError(status, resp) {
+		// let the client retry with the same key
+		h.releaseIdempotencyKey(ctx, key)
+	} else if err := h.idempotency.Complete(ctx, key, &cache.IdempotencyRecord{RequestHash: hash, Status: status, Body: data}, h.idempotencyTTL); err != nil {
+		logger.FromContext(ctx).Warnf("failed to store idempotent response for key %q: %v", key, err)
+	}
+
+	respond(c, status, resp)
+}
+
+func (h *Handler) releaseIdempotencyKey(ctx context.Context, key string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyReleaseTimeout)
+	defer cancel()
+
+	if err := h.idempotency.Release(ctx, key); err != nil {
+		logger.FromContext(ctx).Warnf("failed to release idempotency key %q: %v", key, err)
+	}
+}
+
+// isServerError reports whether a response, or any order result of a batch
+// response, is a server error.
+func isServerError(status int, resp any) bool {
+	if status >= http.StatusInternalServerError {
+		return true
+	}
+
+	if batch, ok := resp.(batchResponse); ok {
+		for _, res := range batch.Results {
+			if res.Status >= http.StatusInternalServerError {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func respond(c *gin.Context, status int, resp any) {
+	if p, ok := resp.(*problem); ok {
+		writeProblem(c, *p)
+		return
+	}
+
+	c.JSON(status, resp)
+}
+
+func badRequest(c *gin.Context, detail string) *problem {
+	return &problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusBadRequest),
+		Status:   http.StatusBadRequest,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+}
+
+// splitNDJSON splits newline-delimited JSON into documents, skipping blank
+// lines.
+func splitNDJSON(body []byte) ([]json.RawMessage, error) {
+	var docs []json.RawMessage
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		docs = append(docs, json.RawMessage(bytes.Clone(line)))
+	}
+
+	return docs, scanner.Err()
+}
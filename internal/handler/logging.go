@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/realdanielursul/order-service/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader       = "X-Request-ID"
+	maxRequestIDLength    = 128
+	requestIDRandomLength = 16
+)
+
+// logRequest attaches a request ID to the request context, taken from the
+// X-Request-ID header or generated, echoes it in the response and writes a
+// structured access log entry once the request is served.
+func logRequest(c *gin.Context) {
+	start := time.Now()
+
+	requestID := c.GetHeader(requestIDHeader)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = newRequestID()
+	}
+
+	c.Header(requestIDHeader, requestID)
+	c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	status := c.Writer.Status()
+	entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+		"method":      c.Request.Method,
+		"route":       route,
+		"path":        c.Request.URL.Path,
+		"status":      status,
+		"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"bytes":       c.Writer.Size(),
+		"client_ip":   c.ClientIP(),
+		"user_agent":  c.Request.UserAgent(),
+	})
+
+	msg := c.Request.Method + " " + c.Request.URL.Path
+	switch {
+	case status >= http.StatusInternalServerError:
+		entry.Error(msg)
+	case status >= http.StatusBadRequest:
+		entry.Warn(msg)
+	default:
+		entry.Info(msg)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, requestIDRandomLength)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
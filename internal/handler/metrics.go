@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+// observeRequest counts requests and records their latency by route and
+// status. Requests matching no route share a single label to keep the
+// number of series bounded.
+func observeRequest(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	status := strconv.Itoa(c.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+	metrics.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
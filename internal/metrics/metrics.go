@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "order_service"
+
+// Cache lookup results of service.GetOrder.
+const (
+	CacheHit      = "hit"
+	CacheMiss     = "miss"
+	CacheNegative = "negative" // known missing order
+	CacheError    = "error"
+)
+
+// Cache tiers and the results of lookups in them.
+const (
+	TierLocal = "local"
+	TierRedis = "redis"
+
+	TierHit   = "hit"
+	TierMiss  = "miss"
+	TierStale = "stale" // expired local entry served while redis is unavailable
+	TierError = "error"
+)
+
+var (
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Messages fetched from Kafka.",
+	}, []string{"topic"})
+
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_failed_total",
+		Help:      "Messages that failed processing, by failure stage.",
+	}, []string{"topic", "stage"})
+
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages behind the high watermark as of the last fetched message, per partition.",
+	}, []string{"topic", "partition"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Order cache lookups, by result.",
+	}, []string{"result"})
+
+	CacheTierRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "tier_requests_total",
+		Help:      "Lookups in each cache tier, by result.",
+	}, []string{"tier", "result"})
+
+	CacheLocalEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "local_entries",
+		Help:      "Entries held by the local cache tier.",
+	})
+
+	CacheLocalBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "local_bytes",
+		Help:      "Bytes held by the local cache tier.",
+	})
+
+	RepositoryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "duration_seconds",
+		Help:      "Latency of repository calls, by method and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests, by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	PreloadDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "preload_duration_seconds",
+		Help:      "Duration of the last cache preload.",
+	})
+
+	PreloadOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "preload_orders",
+		Help:      "Orders written to the cache by the last cache preload.",
+	})
+)
+
+// ObserveRepository records the latency of a repository call started at
+// start. It is deferred with a pointer to the call's named error result.
+func ObserveRepository(method string, start time.Time, errp *error) {
+	outcome := "success"
+	if *errp != nil {
+		outcome = "error"
+	}
+
+	RepositoryDuration.WithLabelValues(method, outcome).Observe(time.Since(start).Seconds())
+}
+
+// ObserveLag records the lag of a partition from a fetched message.
+func ObserveLag(topic string, partition int, offset, highWaterMark int64) {
+	ConsumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(max(highWaterMark-offset-1, 0)))
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/pkg/retry"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	maxFailureBackoff   = time.Minute
+	cleanupInterval     = time.Minute
+)
+
+// Store is the outbox table. repository.Repository and
+// repository.MemoryRepository implement it.
+type Store interface {
+	ProcessOutbox(ctx context.Context, limit int, publish func([]entity.OutboxMessage) error) (int, error)
+	DeleteSentOutbox(ctx context.Context, retention time.Duration, limit int) (int64, error)
+}
+
+// Relay publishes outbox messages to Kafka. A message is marked as sent only
+// after the broker has acknowledged it, so delivery is at-least-once. Sent
+// messages are deleted once they are older than the retention.
+type Relay struct {
+	store     Store
+	writer    *kafka.Writer
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewRelay(store Store, writer *kafka.Writer, cfg config.Outbox) *Relay {
+	r := &Relay{
+		store:     store,
+		writer:    writer,
+		interval:  cfg.PollInterval,
+		batchSize: cfg.BatchSize,
+		retention: cfg.Retention,
+	}
+
+	if r.interval <= 0 {
+		r.interval = defaultPollInterval
+	}
+
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+
+	return r
+}
+
+// Run polls the outbox until ctx is done. Full batches are relayed back to
+// back; failures back off exponentially up to a minute.
+func (r *Relay) Run(ctx context.Context) {
+	backoff := r.interval
+	var lastCleanup time.Time
+
+	for {
+		if r.retention > 0 && time.Since(lastCleanup) >= cleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		n, err := r.store.ProcessOutbox(ctx, r.batchSize, func(msgs []entity.OutboxMessage) error {
+			return r.publish(ctx, msgs)
+		})
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			logrus.Errorf("outbox relay failed, retrying in %s: %v", backoff, err)
+			wait, backoff = backoff, min(backoff*2, maxFailureBackoff)
+		case n == r.batchSize:
+			backoff = r.interval
+		default:
+			wait, backoff = r.interval, r.interval
+		}
+
+		if n > 0 {
+			logrus.Debugf("outbox relay published %d messages", n)
+		}
+
+		if err := retry.Sleep(ctx, wait); err != nil {
+			return
+		}
+	}
+}
+
+// cleanup deletes the sent messages older than the retention, a batch at a
+// time.
+func (r *Relay) cleanup(ctx context.Context) {
+	var total int64
+	for {
+		n, err := r.store.DeleteSentOutbox(ctx, r.retention, r.batchSize)
+		if err != nil {
+			logrus.Errorf("failed to delete sent outbox messages: %v", err)
+			return
+		}
+
+		total += n
+		if n < int64(r.batchSize) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if total > 0 {
+		logrus.Debugf("outbox relay deleted %d sent messages", total)
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msgs []entity.OutboxMessage) error {
+	batch := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		batch = append(batch, kafka.Message{
+			Key:   []byte(m.Key),
+			Value: m.Payload,
+			Headers: []kafka.Header{
+				{Key: "event-type", Value: []byte(m.EventType)},
+			},
+		})
+	}
+
+	return r.writer.WriteMessages(ctx, batch...)
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+// GetAuditHistory returns all recorded writes to an order, oldest first.
+func (r *Repository) GetAuditHistory(ctx context.Context, orderUID string) (_ []entity.AuditEntry, err error) {
+	defer metrics.ObserveRepository("GetAuditHistory", time.Now(), &err)
+	defer classifyError(&err)
+
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	query := `SELECT id, order_uid, action, before, after, source, created_at FROM order_audit WHERE order_uid = $1 ORDER BY id`
+	rows, err := r.QueryxContext(ctx, query, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("get audit history: %w", err)
+	}
+
+	history := []entity.AuditEntry{}
+	err = scanEach(rows, func(rows *sqlx.Rows) error {
+		var row struct {
+			ID        int64              `db:"id"`
+			OrderUID  string             `db:"order_uid"`
+			Action    entity.AuditAction `db:"action"`
+			Before    []byte             `db:"before"`
+			After     []byte             `db:"after"`
+			Source    string             `db:"source"`
+			CreatedAt time.Time          `db:"created_at"`
+		}
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("scan audit entry: %w", err)
+		}
+
+		history = append(history, entity.AuditEntry{
+			ID:        row.ID,
+			OrderUID:  row.OrderUID,
+			Action:    row.Action,
+			Before:    row.Before,
+			After:     row.After,
+			Source:    row.Source,
+			CreatedAt: row.CreatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// insertAudit records a write to an order within tx. A nil before or after
+// snapshot is stored as NULL.
+func insertAudit(ctx context.Context, tx *sqlx.Tx, orderUID string, action entity.AuditAction, before, after *entity.Order) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return fmt.Errorf("marshal audit snapshot: %w", err)
+	}
+
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return fmt.Errorf("marshal audit snapshot: %w", err)
+	}
+
+	query := `INSERT INTO order_audit (order_uid, action, before, after, source) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query, orderUID, action, beforeJSON, afterJSON, audit.SourceFrom(ctx)); err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func snapshot(order *entity.Order) ([]byte, error) {
+	if order == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(order)
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+const (
+	batchOperationTimeout = time.Second * 30
+
+	// orders has 13 columns, which keeps a chunk well below the limit of
+	// 65535 bind parameters per statement
+	insertChunkSize = 1000
+)
+
+var orderColumns = []string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "content_hash", "status"}
+
+// CreateOrders stores a batch of orders in a single transaction and returns
+// one result per order, in the same order. New orders are written with
+// multi-row inserts and their details with COPY; orders that are already
+// stored go through the same conflict handling as CreateOrder. If the bulk
+// load fails, every order is stored on its own under a savepoint, so that a
+// bad order only fails itself. An error is returned only if the whole batch
+// failed.
+func (r *Repository) CreateOrders(ctx context.Context, orders []*entity.Order, policy entity.ConflictPolicy) (_ []entity.BatchResult, err error) {
+	defer metrics.ObserveRepository("CreateOrders", time.Now(), &err)
+	defer classifyError(&err)
+
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, batchOperationTimeout)
+	defer cancel()
+
+	results := make([]entity.BatchResult, len(orders))
+	hashes := make([]string, len(orders))
+	for i, order := range orders {
+		results[i].OrderUID = order.OrderUID
+
+		hashes[i], results[i].Err = order.ContentHash()
+		if results[i].Err != nil {
+			results[i].Err = fmt.Errorf("hash order: %w", results[i].Err)
+		}
+	}
+
+	// begin transaction
+	tx, err := r.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := savepoint(ctx, tx, "SAVEPOINT bulk_load"); err != nil {
+		return nil, err
+	}
+
+	created, err := bulkCreate(ctx, tx, orders, hashes, results)
+	if err != nil {
+		if err := savepoint(ctx, tx, "ROLLBACK TO SAVEPOINT bulk_load"); err != nil {
+			return nil, err
+		}
+
+		created = make([]bool, len(orders))
+	}
+
+	// store the remaining orders one by one
+	for i, order := range orders {
+		if created[i] || results[i].Err != nil {
+			continue
+		}
+
+		if err := savepoint(ctx, tx, "SAVEPOINT order_write"); err != nil {
+			return nil, err
+		}
+
+		octx := audit.WithSource(ctx, audit.SourceFor(ctx, order))
+		results[i].Result, results[i].Err = createOrder(octx, tx, order, hashes[i], policy)
+		if results[i].Err != nil {
+			classifyError(&results[i].Err)
+
+			if err := savepoint(ctx, tx, "ROLLBACK TO SAVEPOINT order_write"); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if err := savepoint(ctx, tx, "RELEASE SAVEPOINT order_write"); err != nil {
+			return nil, err
+		}
+	}
+
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return results, nil
+}
+
+func savepoint(ctx context.Context, tx *sqlx.Tx, stmt string) error {
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("%s: %w", strings.ToLower(stmt), err)
+	}
+
+	return nil
+}
+
+// bulkCreate inserts the orders that are not stored yet along with their
+// details, status history, audit and outbox records, and reports which of
+// them it created. Only the first occurrence of an order_uid in the batch is
+// considered, later ones are left to the caller.
+func bulkCreate(ctx context.Context, tx *sqlx.Tx, orders []*entity.Order, hashes []string, results []entity.BatchResult) ([]bool, error) {
+	var (
+		candidates []int
+		seen       = make(map[string]bool, len(orders))
+	)
+	for i, order := range orders {
+		if results[i].Err != nil || seen[order.OrderUID] {
+			continue
+		}
+
+		seen[order.OrderUID] = true
+		candidates = append(candidates, i)
+	}
+
+	// insert order data in chunks, skipping already known orders
+	inserted := make(map[string]bool, len(candidates))
+	for start := 0; start < len(candidates); start += insertChunkSize {
+		chunk := candidates[start:min(start+insertChunkSize, len(candidates))]
+
+		var (
+			values []string
+			args   = make([]any, 0, len(chunk)*len(orderColumns))
+		)
+		for _, i := range chunk {
+			order := orders[i]
+
+			placeholders := make([]string, len(orderColumns))
+			for j := range placeholders {
+				placeholders[j] = fmt.Sprintf("$%d", len(args)+j+1)
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+
+			args = append(args, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard, hashes[i], order.Status)
+		}
+
+		query := `INSERT INTO orders (` + strings.Join(orderColumns, ", ") + `) VALUES ` + strings.Join(values, ", ") + ` ON CONFLICT (order_uid) DO NOTHING RETURNING order_uid`
+		rows, err := tx.QueryxContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("insert orders: %w", err)
+		}
+
+		err = scanEach(rows, func(rows *sqlx.Rows) error {
+			var orderUID string
+			if err := rows.Scan(&orderUID); err != nil {
+				return fmt.Errorf("scan inserted order: %w", err)
+			}
+
+			inserted[orderUID] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	created := make([]bool, len(orders))
+
+	var delivery, payment, items, history, audits, outbox [][]any
+	for _, i := range candidates {
+		order := orders[i]
+		if !inserted[order.OrderUID] {
+			continue
+		}
+
+		created[i] = true
+		results[i].Result = entity.CreateResultCreated
+
+		d, p := order.Delivery, order.Payment
+		delivery = append(delivery, []any{order.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email})
+		payment = append(payment, []any{p.Transaction, order.OrderUID, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee})
+		for _, item := range order.Items {
+			items = append(items, []any{order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status})
+		}
+
+		source := audit.SourceFor(ctx, order)
+		history = append(history, []any{order.OrderUID, nil, order.Status, source})
+
+		after, err := snapshot(order)
+		if err != nil {
+			return nil, fmt.Errorf("marshal audit snapshot: %w", err)
+		}
+		audits = append(audits, []any{order.OrderUID, entity.AuditActionCreate, nil, string(after), source})
+
+		payload, err := outboxPayload(order, entity.CreateResultCreated)
+		if err != nil {
+			return nil, err
+		}
+		outbox = append(outbox, []any{entity.EventOrderAccepted, order.OrderUID, string(payload)})
+	}
+
+	for _, c := range []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{
+		{"delivery", []string{"order_uid", "name", "phone", "zip", "city", "address", "region", "email"}, delivery},
+		{"payment", []string{"transaction", "order_uid", "request_id", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}, payment},
+		{"items", []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}, items},
+		{"order_status_history", []string{"order_uid", "from_status", "to_status", "source"}, history},
+		{"order_audit", []string{"order_uid", "action", "before", "after", "source"}, audits},
+		{"outbox", []string{"event_type", "key", "payload"}, outbox},
+	} {
+		if err := copyRows(ctx, tx, c.table, c.columns, c.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return created, nil
+}
+
+// copyRows loads rows into table with COPY.
+func copyRows(ctx context.Context, tx *sqlx.Tx, table string, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("copy %s: %w", table, err)
+		}
+	}
+
+	// flush the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+
+	return nil
+}
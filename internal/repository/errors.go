@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+	"github.com/realdanielursul/order-service/internal/errs"
+)
+
+// classifyError wraps a database error into the matching domain error from
+// the errs package. It is deferred by exported methods with a named result.
+func classifyError(errp *error) {
+	err := *errp
+	if err == nil {
+		return
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		*errp = errs.Wrap(errs.ErrNotFound, err)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		*errp = errs.Wrap(errs.ErrTimeout, err)
+		return
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		*errp = errs.Wrap(errs.ErrUnavailable, err)
+		return
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "57014": // query_canceled, raised by statement_timeout
+			*errp = errs.Wrap(errs.ErrTimeout, err)
+		case pqErr.Code == "23505": // unique_violation
+			*errp = errs.Wrap(errs.ErrConflict, err)
+		case pqErr.Code.Class() == "08", // connection exception
+			pqErr.Code.Class() == "40", // transaction rollback
+			pqErr.Code.Class() == "53", // insufficient resources
+			pqErr.Code.Class() == "57": // operator intervention
+			*errp = errs.Wrap(errs.ErrUnavailable, err)
+		}
+
+		return
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			*errp = errs.Wrap(errs.ErrTimeout, err)
+		} else {
+			*errp = errs.Wrap(errs.ErrUnavailable, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+// ListOrders returns one page of orders matching the filter, newest first.
+func (r *Repository) ListOrders(ctx context.Context, filter entity.OrderFilter) (_ *entity.OrderPage, err error) {
+	defer metrics.ObserveRepository("ListOrders", time.Now(), &err)
+	defer classifyError(&err)
+
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	query, args := listQuery(filter)
+
+	rows, err := r.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	defer rows.Close()
+
+	page := &entity.OrderPage{Orders: []*entity.Order{}}
+	for rows.Next() {
+		var order entity.Order
+		if err := rows.StructScan(&order); err != nil {
+			return nil, fmt.Errorf("scan order: %w", err)
+		}
+
+		page.Orders = append(page.Orders, &order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(page.Orders) > filter.Limit {
+		page.Orders = page.Orders[:filter.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = entity.OrderCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}.Encode()
+	}
+
+	if err := loadDetails(ctx, r, page.Orders); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// listQuery builds the query of ListOrders and its arguments. date_created is
+// a TIMESTAMP holding UTC time, and postgres drops the offset of a timestamp
+// bound to it, so times are converted to UTC first.
+func listQuery(filter entity.OrderFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	eq := func(column, value string) {
+		if value != "" {
+			conds = append(conds, column+" = "+arg(value))
+		}
+	}
+
+	eq("o.customer_id", filter.CustomerID)
+	eq("o.delivery_service", filter.DeliveryService)
+	eq("o.locale", filter.Locale)
+	eq("p.currency", filter.Currency)
+	eq("p.provider", filter.Provider)
+	eq("p.bank", filter.Bank)
+
+	if filter.Brand != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = "+arg(filter.Brand)+")")
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "o.date_created >= "+arg(filter.CreatedFrom.UTC()))
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "o.date_created < "+arg(filter.CreatedTo.UTC()))
+	}
+
+	if filter.After != nil {
+		conds = append(conds, "(o.date_created, o.order_uid) < ("+arg(filter.After.DateCreated.UTC())+", "+arg(filter.After.OrderUID)+")")
+	}
+
+	// select one extra row to find out whether there is a next page
+	query := `SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status FROM orders o LEFT JOIN payment p ON p.order_uid = o.order_uid`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY o.date_created DESC, o.order_uid DESC LIMIT " + arg(filter.Limit+1)
+
+	return query, args
+}
+
+// IterateOrders walks all orders matching the filter newest first and passes
+// them to fn in chunks of filter.Limit orders, so that large result sets are
+// never held in memory at once. Iteration stops at the first error returned
+// by fn, which is passed through to the caller.
+func (r *Repository) IterateOrders(ctx context.Context, filter entity.OrderFilter, fn func([]*entity.Order) error) error {
+	return iterateOrders(ctx, r.ListOrders, filter, fn)
+}
+
+func iterateOrders(ctx context.Context, list func(context.Context, entity.OrderFilter) (*entity.OrderPage, error), filter entity.OrderFilter, fn func([]*entity.Order) error) error {
+	for {
+		page, err := list(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		if len(page.Orders) > 0 {
+			if err := fn(page.Orders); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		last := page.Orders[len(page.Orders)-1]
+		filter.After = &entity.OrderCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+}
+
+// loadDetails fills delivery, payment and items data of the given orders
+// with one query per table, reading through the database or a transaction.
+func loadDetails(ctx context.Context, q sqlx.QueryerContext, orders []*entity.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byUID := make(map[string]*entity.Order, len(orders))
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		byUID[order.OrderUID] = order
+		uids = append(uids, order.OrderUID)
+	}
+
+	// select delivery data
+	query := `SELECT order_uid, name, phone, zip, city, address, region, email FROM delivery WHERE order_uid = ANY($1)`
+	rows, err := q.QueryxContext(ctx, query, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("get delivery: %w", err)
+	}
+
+	err = scanEach(rows, func(rows *sqlx.Rows) error {
+		var d struct {
+			OrderUID string `db:"order_uid"`
+			entity.Delivery
+		}
+		if err := rows.StructScan(&d); err != nil {
+			return fmt.Errorf("scan delivery: %w", err)
+		}
+
+		byUID[d.OrderUID].Delivery = d.Delivery
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// select payment data
+	query = `SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payment WHERE order_uid = ANY($1)`
+	rows, err = q.QueryxContext(ctx, query, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+
+	err = scanEach(rows, func(rows *sqlx.Rows) error {
+		var p struct {
+			OrderUID string `db:"order_uid"`
+			entity.Payment
+		}
+		if err := rows.StructScan(&p); err != nil {
+			return fmt.Errorf("scan payment: %w", err)
+		}
+
+		byUID[p.OrderUID].Payment = p.Payment
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// select items data
+	query = `SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = ANY($1) ORDER BY id`
+	rows, err = q.QueryxContext(ctx, query, pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("get items: %w", err)
+	}
+
+	return scanEach(rows, func(rows *sqlx.Rows) error {
+		var i struct {
+			OrderUID string `db:"order_uid"`
+			entity.Item
+		}
+		if err := rows.StructScan(&i); err != nil {
+			return fmt.Errorf("scan item: %w", err)
+		}
+
+		order := byUID[i.OrderUID]
+		order.Items = append(order.Items, i.Item)
+		return nil
+	})
+}
+
+// scanEach calls fn for every row and always closes rows.
+func scanEach(rows *sqlx.Rows, fn func(*sqlx.Rows) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	return nil
+}
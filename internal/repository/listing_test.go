@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+)
+
+func TestListQueryBindsTimesInUTC(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name   string
+		filter entity.OrderFilter
+		cond   string
+		want   time.Time
+	}{
+		{
+			name:   "created_from",
+			filter: entity.OrderFilter{CreatedFrom: time.Date(2021, 11, 26, 9, 0, 0, 0, msk)},
+			cond:   "o.date_created >= $1",
+			want:   time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "created_to",
+			filter: entity.OrderFilter{CreatedTo: time.Date(2021, 11, 26, 0, 30, 0, 0, msk)},
+			cond:   "o.date_created < $1",
+			want:   time.Date(2021, 11, 25, 21, 30, 0, 0, time.UTC),
+		},
+		{
+			name:   "cursor",
+			filter: entity.OrderFilter{After: &entity.OrderCursor{DateCreated: time.Date(2021, 11, 26, 9, 0, 0, 0, msk), OrderUID: "b563feb7b2b84b6test"}},
+			cond:   "(o.date_created, o.order_uid) < ($1, $2)",
+			want:   time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "utc",
+			filter: entity.OrderFilter{CreatedFrom: time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC)},
+			cond:   "o.date_created >= $1",
+			want:   time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 20
+
+			query, args := listQuery(tt.filter)
+			if !strings.Contains(query, tt.cond) {
+				t.Fatalf("expected query to contain %q, got %q", tt.cond, query)
+			}
+
+			got, ok := args[0].(time.Time)
+			if !ok {
+				t.Fatalf("expected a time.Time argument, got %T", args[0])
+			}
+
+			if got.Location() != time.UTC || !got.Equal(tt.want) {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
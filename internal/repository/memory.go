@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+)
+
+// MemoryRepository is an in-memory implementation of the order storage used
+// in standalone mode and tests. Nothing survives a restart.
+type MemoryRepository struct {
+	mu        sync.RWMutex
+	orders    map[string]*memoryOrder
+	conflicts []MemoryConflict
+	history   []entity.StatusChange
+	audit     []entity.AuditEntry
+	outbox    []memoryOutboxMessage
+	outboxSeq int64
+}
+
+type memoryOutboxMessage struct {
+	entity.OutboxMessage
+	claimed bool
+	sentAt  time.Time
+}
+
+type memoryOrder struct {
+	order *entity.Order
+	hash  string
+}
+
+type MemoryConflict struct {
+	OrderUID     string
+	ExistingHash string
+	IncomingHash string
+	Order        *entity.Order
+	ReceivedAt   time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{orders: make(map[string]*memoryOrder)}
+}
+
+func (r *MemoryRepository) CreateOrder(ctx context.Context, order *entity.Order, policy entity.ConflictPolicy) (entity.CreateResult, error) {
+	hash, err := order.ContentHash()
+	if err != nil {
+		return "", fmt.Errorf("hash order: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[order.OrderUID]
+	switch {
+	case !ok:
+		r.orders[order.OrderUID] = &memoryOrder{order: cloneOrder(order), hash: hash}
+		r.history = append(r.history, entity.StatusChange{OrderUID: order.OrderUID, To: order.Status, Source: audit.SourceFrom(ctx), ChangedAt: time.Now()})
+		r.appendAudit(ctx, order.OrderUID, entity.AuditActionCreate, nil, order)
+		r.appendOutbox(order, entity.CreateResultCreated)
+		return entity.CreateResultCreated, nil
+	case existing.hash == hash:
+		return entity.CreateResultDuplicate, nil
+	case policy == entity.ConflictPolicyUpdate:
+		order.Status = existing.order.Status
+		r.orders[order.OrderUID] = &memoryOrder{order: cloneOrder(order), hash: hash}
+		r.appendAudit(ctx, order.OrderUID, entity.AuditActionUpdate, existing.order, order)
+		r.appendOutbox(order, entity.CreateResultUpdated)
+		return entity.CreateResultUpdated, nil
+	default:
+		r.conflicts = append(r.conflicts, MemoryConflict{
+			OrderUID:     order.OrderUID,
+			ExistingHash: existing.hash,
+			IncomingHash: hash,
+			Order:        cloneOrder(order),
+			ReceivedAt:   time.Now(),
+		})
+		return entity.CreateResultConflict, nil
+	}
+}
+
+// CreateOrders stores each order like CreateOrder and reports its outcome.
+func (r *MemoryRepository) CreateOrders(ctx context.Context, orders []*entity.Order, policy entity.ConflictPolicy) ([]entity.BatchResult, error) {
+	results := make([]entity.BatchResult, len(orders))
+	for i, order := range orders {
+		octx := audit.WithSource(ctx, audit.SourceFor(ctx, order))
+
+		results[i].OrderUID = order.OrderUID
+		results[i].Result, results[i].Err = r.CreateOrder(octx, order, policy)
+	}
+
+	return results, nil
+}
+
+func (r *MemoryRepository) GetOrder(ctx context.Context, orderUID string) (*entity.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.orders[orderUID]
+	if !ok {
+		return nil, fmt.Errorf("get order %q: %w", orderUID, errs.ErrNotFound)
+	}
+
+	return cloneOrder(stored.order), nil
+}
+
+func (r *MemoryRepository) UpdateStatus(ctx context.Context, orderUID string, from, to entity.OrderStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.orders[orderUID]
+	if !ok {
+		return fmt.Errorf("get order %q: %w", orderUID, errs.ErrNotFound)
+	}
+
+	if stored.order.Status != from {
+		return fmt.Errorf("order %q is %s, expected %s: %w", orderUID, stored.order.Status, from, errs.ErrConflict)
+	}
+
+	before := cloneOrder(stored.order)
+	stored.order.Status = to
+	r.history = append(r.history, entity.StatusChange{OrderUID: orderUID, From: from, To: to, Source: audit.SourceFrom(ctx), ChangedAt: time.Now()})
+	r.appendAudit(ctx, orderUID, entity.AuditActionStatusChange, before, stored.order)
+	return nil
+}
+
+func (r *MemoryRepository) GetAuditHistory(ctx context.Context, orderUID string) ([]entity.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := []entity.AuditEntry{}
+	for _, e := range r.audit {
+		if e.OrderUID == orderUID {
+			history = append(history, e)
+		}
+	}
+
+	return history, nil
+}
+
+func (r *MemoryRepository) ProcessOutbox(ctx context.Context, limit int, publish func([]entity.OutboxMessage) error) (int, error) {
+	pending := r.claimOutbox(limit)
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	err := publish(pending)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make(map[int64]bool, len(pending))
+	for _, m := range pending {
+		ids[m.ID] = true
+	}
+
+	for i := range r.outbox {
+		if m := &r.outbox[i]; ids[m.ID] {
+			m.claimed = false
+			m.Attempts++
+			if err == nil {
+				m.sentAt = time.Now()
+			}
+		}
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("publish outbox messages: %w", err)
+	}
+
+	return len(pending), nil
+}
+
+func (r *MemoryRepository) claimOutbox(limit int) []entity.OutboxMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending []entity.OutboxMessage
+	for i := range r.outbox {
+		if len(pending) == limit {
+			break
+		}
+
+		if m := &r.outbox[i]; m.sentAt.IsZero() && !m.claimed {
+			m.claimed = true
+			pending = append(pending, m.OutboxMessage)
+		}
+	}
+
+	return pending
+}
+
+func (r *MemoryRepository) DeleteSentOutbox(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	r.outbox = slices.DeleteFunc(r.outbox, func(m memoryOutboxMessage) bool {
+		if deleted == int64(limit) || m.sentAt.IsZero() || time.Since(m.sentAt) < retention {
+			return false
+		}
+
+		deleted++
+		return true
+	})
+
+	return deleted, nil
+}
+
+// appendOutbox must be called with the write lock held.
+func (r *MemoryRepository) appendOutbox(order *entity.Order, result entity.CreateResult) {
+	payload, _ := json.Marshal(entity.OrderEvent{
+		Type:       entity.EventOrderAccepted,
+		OrderUID:   order.OrderUID,
+		Result:     result,
+		Order:      order,
+		OccurredAt: time.Now().UTC(),
+	})
+
+	r.outboxSeq++
+	r.outbox = append(r.outbox, memoryOutboxMessage{OutboxMessage: entity.OutboxMessage{
+		ID:        r.outboxSeq,
+		EventType: entity.EventOrderAccepted,
+		Key:       order.OrderUID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}})
+}
+
+// appendAudit must be called with the write lock held.
+func (r *MemoryRepository) appendAudit(ctx context.Context, orderUID string, action entity.AuditAction, before, after *entity.Order) {
+	e := entity.AuditEntry{
+		ID:        int64(len(r.audit) + 1),
+		OrderUID:  orderUID,
+		Action:    action,
+		Source:    audit.SourceFrom(ctx),
+		CreatedAt: time.Now(),
+	}
+
+	// snapshots of plain structs cannot fail to marshal
+	e.Before, _ = snapshot(before)
+	e.After, _ = snapshot(after)
+
+	r.audit = append(r.audit, e)
+}
+
+func (r *MemoryRepository) ListOrders(ctx context.Context, filter entity.OrderFilter) (*entity.OrderPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []*entity.Order
+	for _, stored := range r.orders {
+		if matchesFilter(stored.order, filter) {
+			matched = append(matched, stored.order)
+		}
+	}
+
+	// newest first, same order as the postgres listing
+	slices.SortFunc(matched, func(a, b *entity.Order) int {
+		if c := b.DateCreated.Compare(a.DateCreated); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(b.OrderUID, a.OrderUID)
+	})
+
+	page := &entity.OrderPage{Orders: []*entity.Order{}}
+	for _, order := range matched {
+		if len(page.Orders) == filter.Limit {
+			last := page.Orders[len(page.Orders)-1]
+			page.NextCursor = entity.OrderCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}.Encode()
+			break
+		}
+
+		page.Orders = append(page.Orders, cloneOrder(order))
+	}
+
+	return page, nil
+}
+
+func (r *MemoryRepository) IterateOrders(ctx context.Context, filter entity.OrderFilter, fn func([]*entity.Order) error) error {
+	return iterateOrders(ctx, r.ListOrders, filter, fn)
+}
+
+// Conflicts returns the recorded conflict log.
+func (r *MemoryRepository) Conflicts() []MemoryConflict {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.conflicts)
+}
+
+func matchesFilter(o *entity.Order, f entity.OrderFilter) bool {
+	eq := func(filter, value string) bool { return filter == "" || filter == value }
+
+	if !eq(f.CustomerID, o.CustomerID) || !eq(f.DeliveryService, o.DeliveryService) || !eq(f.Locale, o.Locale) ||
+		!eq(f.Currency, o.Payment.Currency) || !eq(f.Provider, o.Payment.Provider) || !eq(f.Bank, o.Payment.Bank) {
+		return false
+	}
+
+	if f.Brand != "" && !slices.ContainsFunc(o.Items, func(i entity.Item) bool { return i.Brand == f.Brand }) {
+		return false
+	}
+
+	if !f.CreatedFrom.IsZero() && o.DateCreated.Before(f.CreatedFrom) {
+		return false
+	}
+
+	if !f.CreatedTo.IsZero() && !o.DateCreated.Before(f.CreatedTo) {
+		return false
+	}
+
+	if f.After != nil {
+		c := o.DateCreated.Compare(f.After.DateCreated)
+		if c > 0 || (c == 0 && o.OrderUID >= f.After.OrderUID) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func cloneOrder(o *entity.Order) *entity.Order {
+	c := *o
+	c.Items = slices.Clone(o.Items)
+	return &c
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
+)
+
+func TestMemoryOutbox(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	for _, orderUID := range []string{"a", "b", "c"} {
+		if _, err := r.CreateOrder(ctx, &entity.Order{OrderUID: orderUID}, entity.ConflictPolicyReject); err != nil {
+			t.Fatalf("create order %q: %v", orderUID, err)
+		}
+	}
+
+	var published []string
+	publish := func(msgs []entity.OutboxMessage) error {
+		for _, m := range msgs {
+			published = append(published, m.Key)
+		}
+		return nil
+	}
+
+	// a failed publish leaves the messages pending
+	if _, err := r.ProcessOutbox(ctx, 2, func([]entity.OutboxMessage) error { return errors.New("broker down") }); err == nil {
+		t.Fatal("expected the publish error")
+	}
+
+	if n, err := r.ProcessOutbox(ctx, 2, publish); err != nil || n != 2 {
+		t.Fatalf("expected 2 messages published, got %d: %v", n, err)
+	}
+
+	if n, err := r.ProcessOutbox(ctx, 2, publish); err != nil || n != 1 {
+		t.Fatalf("expected 1 message published, got %d: %v", n, err)
+	}
+
+	if len(published) != 3 || published[0] != "a" || published[1] != "b" || published[2] != "c" {
+		t.Errorf("expected a, b and c published in order, got %v", published)
+	}
+
+	if r.outbox[0].Attempts != 2 || r.outbox[2].Attempts != 1 {
+		t.Errorf("expected 2 attempts for a and 1 for c, got %d and %d", r.outbox[0].Attempts, r.outbox[2].Attempts)
+	}
+
+	// sent messages are kept for the retention
+	if n, _ := r.DeleteSentOutbox(ctx, time.Hour, 10); n != 0 {
+		t.Errorf("expected no messages deleted within the retention, got %d", n)
+	}
+
+	if n, _ := r.DeleteSentOutbox(ctx, 0, 2); n != 2 {
+		t.Errorf("expected 2 messages deleted, got %d", n)
+	}
+
+	if len(r.outbox) != 1 || r.outbox[0].Key != "c" {
+		t.Errorf("expected only c left, got %d messages", len(r.outbox))
+	}
+}
+
+func TestMemoryCreateOrdersKeepsSourcePerOrder(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	first := &entity.Order{OrderUID: "same", TrackNumber: "FIRST"}
+	second := &entity.Order{OrderUID: "same", TrackNumber: "SECOND"}
+
+	ctx = audit.WithOrderSources(ctx, map[*entity.Order]string{
+		first:  audit.KafkaSource("order", 0, 10),
+		second: audit.KafkaSource("order", 0, 11),
+	})
+
+	if _, err := r.CreateOrders(ctx, []*entity.Order{first, second}, entity.ConflictPolicyUpdate); err != nil {
+		t.Fatalf("create orders: %v", err)
+	}
+
+	history, err := r.GetAuditHistory(ctx, "same")
+	if err != nil {
+		t.Fatalf("get audit history: %v", err)
+	}
+
+	var sources []string
+	for _, e := range history {
+		sources = append(sources, e.Source)
+	}
+
+	want := []string{"kafka:order/0/10", "kafka:order/0/11"}
+	if !slices.Equal(sources, want) {
+		t.Errorf("expected sources %v, got %v", want, sources)
+	}
+}
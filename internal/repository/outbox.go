@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+// outboxClaimTTL is how long a relay owns the messages it claimed. Messages
+// of a relay that stopped before marking them are claimed again after it.
+const outboxClaimTTL = time.Minute
+
+// ProcessOutbox claims up to limit pending outbox messages, hands them to
+// publish and marks them as sent if it succeeds, or records the failed
+// attempt otherwise. The claim is committed before publishing, so no
+// transaction is held open while waiting for Kafka, and claimed messages are
+// skipped by other relays running against the same database.
+func (r *Repository) ProcessOutbox(ctx context.Context, limit int, publish func([]entity.OutboxMessage) error) (_ int, err error) {
+	defer metrics.ObserveRepository("ProcessOutbox", time.Now(), &err)
+	defer classifyError(&err)
+
+	msgs, err := r.claimOutbox(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+
+	// publish and record the outcome
+	publishErr := publish(msgs)
+	if err := r.markOutbox(ctx, ids, publishErr); err != nil {
+		return 0, err
+	}
+
+	if publishErr != nil {
+		return 0, fmt.Errorf("publish outbox messages: %w", publishErr)
+	}
+
+	return len(msgs), nil
+}
+
+func (r *Repository) claimOutbox(ctx context.Context, limit int) ([]entity.OutboxMessage, error) {
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	var msgs []entity.OutboxMessage
+
+	query := `UPDATE outbox SET claimed_until = now() + make_interval(secs => $2) WHERE id IN (SELECT id FROM outbox WHERE sent_at IS NULL AND (claimed_until IS NULL OR claimed_until < now()) ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING id, event_type, key, payload, attempts, created_at`
+	if err := r.SelectContext(ctx, &msgs, query, limit, outboxClaimTTL.Seconds()); err != nil {
+		return nil, fmt.Errorf("claim outbox messages: %w", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(msgs, func(a, b entity.OutboxMessage) int { return cmp.Compare(a.ID, b.ID) })
+
+	return msgs, nil
+}
+
+func (r *Repository) markOutbox(ctx context.Context, ids []int64, publishErr error) error {
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	if publishErr != nil {
+		query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2, claimed_until = NULL WHERE id = ANY($1)`
+		if _, err := r.ExecContext(ctx, query, pq.Array(ids), publishErr.Error()); err != nil {
+			return fmt.Errorf("mark outbox messages failed: %w", err)
+		}
+
+		return nil
+	}
+
+	query := `UPDATE outbox SET attempts = attempts + 1, sent_at = now(), last_error = NULL, claimed_until = NULL WHERE id = ANY($1)`
+	if _, err := r.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("mark outbox messages sent: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteSentOutbox deletes up to limit outbox messages that were sent more
+// than retention ago and returns how many it deleted.
+func (r *Repository) DeleteSentOutbox(ctx context.Context, retention time.Duration, limit int) (_ int64, err error) {
+	defer metrics.ObserveRepository("DeleteSentOutbox", time.Now(), &err)
+	defer classifyError(&err)
+
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	query := `DELETE FROM outbox WHERE id IN (SELECT id FROM outbox WHERE sent_at < now() - make_interval(secs => $1) LIMIT $2)`
+	res, err := r.ExecContext(ctx, query, retention.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("delete sent outbox messages: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// insertOutbox stores an "order accepted" event within tx.
+func insertOutbox(ctx context.Context, tx *sqlx.Tx, order *entity.Order, result entity.CreateResult) error {
+	payload, err := outboxPayload(order, result)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outbox (event_type, key, payload) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, entity.EventOrderAccepted, order.OrderUID, payload); err != nil {
+		return fmt.Errorf("insert outbox message: %w", err)
+	}
+
+	return nil
+}
+
+func outboxPayload(order *entity.Order, result entity.CreateResult) ([]byte, error) {
+	payload, err := json.Marshal(entity.OrderEvent{
+		Type:       entity.EventOrderAccepted,
+		OrderUID:   order.OrderUID,
+		Result:     result,
+		Order:      order,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal order event: %w", err)
+	}
+
+	return payload, nil
+}
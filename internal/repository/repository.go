@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/realdanielursul/order-service/internal/audit"
 	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
 )
 
 const operationTimeout = time.Second * 5
@@ -20,165 +23,229 @@ func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{db}
 }
 
-func (r *Repository) CreateOrder(ctx context.Context, order *entity.Order) error {
+func (r *Repository) CreateOrder(ctx context.Context, order *entity.Order, policy entity.ConflictPolicy) (_ entity.CreateResult, err error) {
+	defer metrics.ObserveRepository("CreateOrder", time.Now(), &err)
+	defer classifyError(&err)
+
 	// set context timeout
 	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
 	defer cancel()
 
+	hash, err := order.ContentHash()
+	if err != nil {
+		return "", fmt.Errorf("hash order: %w", err)
+	}
+
 	// begin transaction
 	tx, err := r.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return "", fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	// insert order data
-	query := `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = tx.ExecContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard)
+	result, err := createOrder(ctx, tx, order, hash, policy)
 	if err != nil {
-		return fmt.Errorf("insert order: %w", err)
+		return "", err
 	}
 
-	// insert delivery data
-	query = `INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err = tx.ExecContext(ctx, query, order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit tx: %w", err)
+	}
+
+	return result, nil
+}
+
+// createOrder stores order within tx and reports what happened to it.
+func createOrder(ctx context.Context, tx *sqlx.Tx, order *entity.Order, hash string, policy entity.ConflictPolicy) (entity.CreateResult, error) {
+	// insert order data, skipping already known orders
+	query := `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, content_hash, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT (order_uid) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard, hash, order.Status)
 	if err != nil {
-		return fmt.Errorf("insert delivery: %w", err)
+		return "", fmt.Errorf("insert order: %w", err)
 	}
 
-	// insert payment data
-	query = `INSERT INTO payment (transaction, order_uid, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = tx.ExecContext(ctx, query, order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
+	inserted, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("insert payment: %w", err)
+		return "", fmt.Errorf("insert order: %w", err)
 	}
 
-	// insert items data
-	for _, item := range order.Items {
-		query = `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-		_, err = tx.ExecContext(ctx, query, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status)
+	result := entity.CreateResultCreated
+	if inserted == 0 {
+		result, err = resolveExisting(ctx, tx, order, hash, policy)
 		if err != nil {
-			return fmt.Errorf("insert item: %w", err)
+			return "", err
 		}
 	}
 
-	// commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit tx: %w", err)
+	if result == entity.CreateResultCreated {
+		if err := insertDetails(ctx, tx, order); err != nil {
+			return "", err
+		}
+
+		// record the initial status
+		query = `INSERT INTO order_status_history (order_uid, from_status, to_status, source) VALUES ($1, NULL, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, order.OrderUID, order.Status, audit.SourceFrom(ctx)); err != nil {
+			return "", fmt.Errorf("insert status history: %w", err)
+		}
+
+		if err := insertAudit(ctx, tx, order.OrderUID, entity.AuditActionCreate, nil, order); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	// publish the write through the outbox
+	if result == entity.CreateResultCreated || result == entity.CreateResultUpdated {
+		if err := insertOutbox(ctx, tx, order, result); err != nil {
+			return "", err
+		}
+	}
+
+	return result, nil
 }
 
-func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*entity.Order, error) {
-	// set context timeout
-	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
-	defer cancel()
+// resolveExisting decides what to do with an order whose order_uid is already
+// stored: identical content is a duplicate, different content is either
+// written over the stored order or recorded in the conflict log.
+func resolveExisting(ctx context.Context, tx *sqlx.Tx, order *entity.Order, hash string, policy entity.ConflictPolicy) (entity.CreateResult, error) {
+	var existing sql.NullString
 
-	var order entity.Order
+	query := `SELECT content_hash FROM orders WHERE order_uid = $1 FOR UPDATE`
+	if err := tx.QueryRowxContext(ctx, query, order.OrderUID).Scan(&existing); err != nil {
+		return "", fmt.Errorf("get existing order: %w", err)
+	}
 
-	// select order data
-	query := `SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders WHERE order_uid = $1`
-	if err := r.QueryRowxContext(ctx, query, orderUID).StructScan(&order); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+	// orders stored before content hashes were introduced have none, so
+	// hash the stored content and keep the result for later redeliveries
+	var before *entity.Order
+	if !existing.Valid {
+		var err error
+		if before, err = getOrder(ctx, tx, order.OrderUID); err != nil {
+			return "", err
 		}
 
-		return nil, fmt.Errorf("get order: %w", err)
-	}
+		storedHash, err := before.ContentHash()
+		if err != nil {
+			return "", fmt.Errorf("hash existing order: %w", err)
+		}
 
-	// select delivery data
-	query = `SELECT name, phone, zip, city, address, region, email FROM delivery WHERE order_uid = $1`
-	if err := r.QueryRowxContext(ctx, query, orderUID).StructScan(&order.Delivery); err != nil {
-		return nil, fmt.Errorf("get delivery: %w", err)
+		query = `UPDATE orders SET content_hash = $2 WHERE order_uid = $1`
+		if _, err := tx.ExecContext(ctx, query, order.OrderUID, storedHash); err != nil {
+			return "", fmt.Errorf("backfill content hash: %w", err)
+		}
+
+		existing = sql.NullString{String: storedHash, Valid: true}
 	}
 
-	// select payment data
-	query = `SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payment WHERE order_uid = $1`
-	if err := r.QueryRowxContext(ctx, query, orderUID).StructScan(&order.Payment); err != nil {
-		return nil, fmt.Errorf("get payment: %w", err)
+	if existing.String == hash {
+		return entity.CreateResultDuplicate, nil
 	}
 
-	// select items data
-	query = `SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1`
-	rows, err := r.QueryxContext(ctx, query, orderUID)
-	if err != nil {
-		return nil, fmt.Errorf("get items: %w", err)
+	if policy != entity.ConflictPolicyUpdate {
+		payload, err := json.Marshal(order)
+		if err != nil {
+			return "", fmt.Errorf("marshal conflicting order: %w", err)
+		}
+
+		query = `INSERT INTO order_conflicts (order_uid, existing_hash, incoming_hash, payload) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.ExecContext(ctx, query, order.OrderUID, existing, hash, payload); err != nil {
+			return "", fmt.Errorf("insert conflict: %w", err)
+		}
+
+		return entity.CreateResultConflict, nil
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var item entity.Item
-		if err := rows.StructScan(&item); err != nil {
-			return nil, fmt.Errorf("get item: %w", err)
+	if before == nil {
+		var err error
+		if before, err = getOrder(ctx, tx, order.OrderUID); err != nil {
+			return "", err
 		}
+	}
 
-		order.Items = append(order.Items, item)
+	// update order data, keeping its current status
+	query = `UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5, customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10, oof_shard = $11, content_hash = $12 WHERE order_uid = $1 RETURNING status`
+	if err := tx.QueryRowxContext(ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard, hash).Scan(&order.Status); err != nil {
+		return "", fmt.Errorf("update order: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+	// replace delivery, payment and items data
+	if err := deleteDetails(ctx, tx, order.OrderUID); err != nil {
+		return "", err
 	}
 
-	return &order, nil
-}
+	if err := insertDetails(ctx, tx, order); err != nil {
+		return "", err
+	}
 
-func (r *Repository) GetAllOrders(ctx context.Context) ([]*entity.Order, error) {
-	// set context timeout
-	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
-	defer cancel()
+	if err := insertAudit(ctx, tx, order.OrderUID, entity.AuditActionUpdate, before, order); err != nil {
+		return "", err
+	}
 
-	var orders []*entity.Order
+	return entity.CreateResultUpdated, nil
+}
 
-	// select all orders
-	query := `SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders`
-	rows, err := r.QueryxContext(ctx, query)
+func insertDetails(ctx context.Context, tx *sqlx.Tx, order *entity.Order) error {
+	// insert delivery data
+	query := `INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.ExecContext(ctx, query, order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
 	if err != nil {
-		return nil, fmt.Errorf("get all orders: %w", err)
+		return fmt.Errorf("insert delivery: %w", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var order entity.Order
-		if err := rows.StructScan(&order); err != nil {
-			return nil, fmt.Errorf("scan order: %w", err)
-		}
+	// insert payment data
+	query = `INSERT INTO payment (transaction, order_uid, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = tx.ExecContext(ctx, query, order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
+	if err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
 
-		// select delivery data
-		query = `SELECT name, phone, zip, city, address, region, email FROM delivery WHERE order_uid = $1`
-		if err := r.QueryRowxContext(ctx, query, order.OrderUID).StructScan(&order.Delivery); err != nil {
-			return nil, fmt.Errorf("get delivery: %w", err)
+	// insert items data
+	for _, item := range order.Items {
+		query = `INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		_, err = tx.ExecContext(ctx, query, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status)
+		if err != nil {
+			return fmt.Errorf("insert item: %w", err)
 		}
+	}
 
-		// select payment data
-		query = `SELECT transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee FROM payment WHERE order_uid = $1`
-		if err := r.QueryRowxContext(ctx, query, order.OrderUID).StructScan(&order.Payment); err != nil {
-			return nil, fmt.Errorf("get payment: %w", err)
-		}
+	return nil
+}
 
-		// select items data
-		query = `SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1`
-		rows, err := r.QueryxContext(ctx, query, order.OrderUID)
-		if err != nil {
-			return nil, fmt.Errorf("get items: %w", err)
+func deleteDetails(ctx context.Context, tx *sqlx.Tx, orderUID string) error {
+	for _, table := range []string{"items", "payment", "delivery"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid = $1`, orderUID); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
 		}
-		defer rows.Close()
+	}
 
-		for rows.Next() {
-			var item entity.Item
-			if err := rows.StructScan(&item); err != nil {
-				return nil, fmt.Errorf("scan item: %w", err)
-			}
+	return nil
+}
 
-			order.Items = append(order.Items, item)
-		}
+func (r *Repository) GetOrder(ctx context.Context, orderUID string) (_ *entity.Order, err error) {
+	defer metrics.ObserveRepository("GetOrder", time.Now(), &err)
+	defer classifyError(&err)
 
-		orders = append(orders, &order)
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	return getOrder(ctx, r, orderUID)
+}
+
+// getOrder loads a full order through q, which may be the database or an
+// open transaction.
+func getOrder(ctx context.Context, q sqlx.QueryerContext, orderUID string) (*entity.Order, error) {
+	var order entity.Order
+
+	// select order data
+	query := `SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status FROM orders WHERE order_uid = $1`
+	if err := q.QueryRowxContext(ctx, query, orderUID).StructScan(&order); err != nil {
+		return nil, fmt.Errorf("get order: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+	if err := loadDetails(ctx, q, []*entity.Order{&order}); err != nil {
+		return nil, err
 	}
 
-	return orders, nil
+	return &order, nil
 }
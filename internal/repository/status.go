@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/audit"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/metrics"
+)
+
+// UpdateStatus moves an order from status from to status to and records the
+// transition with the source attached to ctx. It fails with errs.ErrConflict
+// if the order is no longer in status from, and with errs.ErrNotFound if it
+// does not exist.
+func (r *Repository) UpdateStatus(ctx context.Context, orderUID string, from, to entity.OrderStatus) (err error) {
+	defer metrics.ObserveRepository("UpdateStatus", time.Now(), &err)
+	defer classifyError(&err)
+
+	// set context timeout
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	// begin transaction
+	tx, err := r.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// update status if nobody changed it in the meantime
+	var current entity.OrderStatus
+
+	query := `SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE`
+	if err := tx.QueryRowxContext(ctx, query, orderUID).Scan(&current); err != nil {
+		return fmt.Errorf("get order status: %w", err)
+	}
+
+	if current != from {
+		return fmt.Errorf("order %q is %s, expected %s: %w", orderUID, current, from, errs.ErrConflict)
+	}
+
+	before, err := getOrder(ctx, tx, orderUID)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE orders SET status = $2 WHERE order_uid = $1`
+	if _, err := tx.ExecContext(ctx, query, orderUID, to); err != nil {
+		return fmt.Errorf("update order status: %w", err)
+	}
+
+	// insert status history
+	query = `INSERT INTO order_status_history (order_uid, from_status, to_status, source) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, orderUID, from, to, audit.SourceFrom(ctx)); err != nil {
+		return fmt.Errorf("insert status history: %w", err)
+	}
+
+	after := *before
+	after.Status = to
+	if err := insertAudit(ctx, tx, orderUID, entity.AuditActionStatusChange, before, &after); err != nil {
+		return err
+	}
+
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
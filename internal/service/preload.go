@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/metrics"
+	"github.com/realdanielursul/order-service/pkg/logger"
+)
+
+const (
+	defaultPreloadBatchSize = 500
+
+	// how often a running preload reports its progress
+	preloadProgressInterval = 5 * time.Second
+)
+
+var errPreloadLimitReached = errors.New("preload limit reached")
+
+type preloadState struct {
+	loaded atomic.Int64
+	done   atomic.Bool
+	err    atomic.Pointer[error]
+}
+
+// PreloadStatus reports how many orders have been written to the cache so far,
+// whether the preload has finished and, if so, why it failed.
+func (s *Service) PreloadStatus() (loaded int64, done bool, err error) {
+	if errp := s.preload.err.Load(); errp != nil {
+		err = *errp
+	}
+
+	return s.preload.loaded.Load(), s.preload.done.Load(), err
+}
+
+// PreloadCache warms the cache with the most recent orders, streaming them
+// from the repository page by page and writing each page in one pipelined
+// batch. Limits come from the preload config.
+func (s *Service) PreloadCache(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			s.preload.err.Store(&err)
+		}
+		s.preload.done.Store(true)
+	}()
+
+	cfg := s.preloadCfg
+	if !cfg.Enabled {
+		logger.FromContext(ctx).Info("cache preload disabled")
+		return nil
+	}
+
+	filter := entity.OrderFilter{Limit: cfg.BatchSize}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPreloadBatchSize
+	}
+
+	if cfg.Limit > 0 && cfg.Limit < filter.Limit {
+		filter.Limit = cfg.Limit
+	}
+
+	if cfg.Window > 0 {
+		filter.CreatedFrom = time.Now().UTC().Add(-cfg.Window)
+	}
+
+	start := time.Now()
+	lastReport := start
+	err = s.repository.IterateOrders(ctx, filter, func(orders []*entity.Order) error {
+		loaded := s.preload.loaded.Load()
+		if cfg.Limit > 0 && loaded+int64(len(orders)) > int64(cfg.Limit) {
+			orders = orders[:int64(cfg.Limit)-loaded]
+		}
+
+		entries := make(map[string][]byte, len(orders))
+		for _, order := range orders {
+			data, err := json.Marshal(order)
+			if err != nil {
+				logger.FromContext(logger.WithOrderUID(ctx, order.OrderUID)).Warnf("failed to marshal order %q: %v", order.OrderUID, err)
+				continue
+			}
+
+			entries[order.OrderUID] = data
+		}
+
+		if err := s.cache.SetMany(ctx, entries); err != nil {
+			return fmt.Errorf("write batch to cache: %w", err)
+		}
+
+		loaded = s.preload.loaded.Add(int64(len(entries)))
+		if time.Since(lastReport) >= preloadProgressInterval {
+			logger.FromContext(ctx).Infof("Preloading cache: %d orders loaded", loaded)
+			lastReport = time.Now()
+		} else {
+			logger.FromContext(ctx).Debugf("Preloading cache: %d orders loaded", loaded)
+		}
+
+		if cfg.Limit > 0 && loaded >= int64(cfg.Limit) {
+			return errPreloadLimitReached
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPreloadLimitReached) {
+		return fmt.Errorf("failed to preload cache: %w", err)
+	}
+
+	elapsed := time.Since(start)
+	metrics.PreloadDuration.Set(elapsed.Seconds())
+	metrics.PreloadOrders.Set(float64(s.preload.loaded.Load()))
+
+	logger.FromContext(ctx).Infof("Preloaded %d orders into cache in %s", s.preload.loaded.Load(), elapsed.Round(time.Millisecond))
+	return nil
+}
@@ -3,106 +3,307 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/realdanielursul/order-service/config"
 	"github.com/realdanielursul/order-service/internal/cache"
 	"github.com/realdanielursul/order-service/internal/entity"
-	"github.com/realdanielursul/order-service/internal/repository"
-	"github.com/redis/go-redis/v9"
-	"github.com/sirupsen/logrus"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/metrics"
+	"github.com/realdanielursul/order-service/pkg/logger"
+	"github.com/realdanielursul/order-service/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var tracer = otel.Tracer("github.com/realdanielursul/order-service/internal/service")
+
 type Service struct {
-	cache      *cache.Cache
-	repository *repository.Repository
+	cache      OrderCache
+	repository OrderRepository
+	policy     entity.ConflictPolicy
+
+	coalesce    bool
+	loads       singleflight.Group
+	loadJoined  func() // called once a caller has joined a shared load, for tests
+	negativeTTL time.Duration
+
+	preloadCfg config.Preload
+	preload    preloadState
 }
 
-func NewService(c *cache.Cache, r *repository.Repository) *Service {
-	return &Service{cache: c, repository: r}
+func NewService(c OrderCache, r OrderRepository, cfg config.Service) *Service {
+	return &Service{
+		cache:       c,
+		repository:  r,
+		policy:      entity.ConflictPolicy(cfg.ConflictPolicy),
+		coalesce:    cfg.CoalesceReads,
+		negativeTTL: cfg.NegativeTTL,
+		preloadCfg:  cfg.Preload,
+	}
 }
 
-func (s *Service) GetOrder(ctx context.Context, orderUID string) (*entity.Order, error) {
+func (s *Service) GetOrder(ctx context.Context, orderUID string) (_ *entity.Order, err error) {
+	ctx = logger.WithOrderUID(ctx, orderUID)
+	ctx, span := tracer.Start(ctx, "service.GetOrder", trace.WithAttributes(attribute.String("order.uid", orderUID)))
+	defer func() {
+		// a missing order is an answer, not a failure
+		if errors.Is(err, errs.ErrNotFound) {
+			span.End()
+			return
+		}
+
+		tracing.End(span, err)
+	}()
+
 	// try to fetch data from cache
 	data, err := s.cache.GetData(ctx, orderUID)
-	if err != nil && err != redis.Nil {
-		return nil, fmt.Errorf("get from cache: %w", err)
-	}
+	switch {
+	case err == nil:
+		metrics.CacheRequests.WithLabelValues(metrics.CacheHit).Inc()
 
-	if data != nil {
 		var order entity.Order
 		if err := json.Unmarshal(data, &order); err != nil {
 			return nil, fmt.Errorf("unmarshal cached order: %w", err)
 		}
 
-		return &order, err
+		return &order, nil
+	case errors.Is(err, cache.ErrNegativeHit):
+		metrics.CacheRequests.WithLabelValues(metrics.CacheNegative).Inc()
+		return nil, fmt.Errorf("order %q: %w", orderUID, errs.ErrNotFound)
+	case errors.Is(err, errs.ErrNotFound):
+		metrics.CacheRequests.WithLabelValues(metrics.CacheMiss).Inc()
+	default:
+		metrics.CacheRequests.WithLabelValues(metrics.CacheError).Inc()
+		logger.FromContext(ctx).Warnf("failed to get order %q from cache, falling back to db: %v", orderUID, err)
+	}
+
+	if !s.coalesce {
+		return s.loadOrder(ctx, orderUID)
+	}
+
+	// share a single db load between concurrent requests for the same order;
+	// the load is detached from the first caller's cancellation so that it
+	// does not fail the other waiters
+	ch := s.loads.DoChan(orderUID, func() (any, error) {
+		return s.loadOrder(context.WithoutCancel(ctx), orderUID)
+	})
+	if s.loadJoined != nil {
+		s.loadJoined()
 	}
 
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		// callers may modify the order, so each gets its own copy
+		order := *res.Val.(*entity.Order)
+		order.Items = slices.Clone(order.Items)
+		return &order, nil
+	}
+}
+
+// loadOrder reads an order from the database and caches the outcome,
+// including a short-lived negative entry when the order does not exist.
+func (s *Service) loadOrder(ctx context.Context, orderUID string) (*entity.Order, error) {
 	// get data from database
 	order, err := s.repository.GetOrder(ctx, orderUID)
 	if err != nil {
-		return nil, fmt.Errorf("get from repository: %w", err)
-	}
+		if errors.Is(err, errs.ErrNotFound) {
+			if s.negativeTTL > 0 {
+				if err := s.cache.SetMissing(ctx, orderUID, s.negativeTTL); err != nil {
+					logger.FromContext(ctx).Warnf("failed to cache missing order %q: %v", orderUID, err)
+				}
+			}
+
+			return nil, fmt.Errorf("order %q: %w", orderUID, errs.ErrNotFound)
+		}
 
-	if order == nil {
-		return nil, fmt.Errorf("order not found")
+		return nil, fmt.Errorf("get from repository: %w", err)
 	}
 
 	// set new data to cache
-	data, err = json.Marshal(order)
+	data, err := json.Marshal(order)
 	if err != nil {
 		return nil, fmt.Errorf("marshal new order: %w", err)
 	}
 
 	if err := s.cache.SetData(ctx, orderUID, data); err != nil {
-		logrus.Warn("failed to cache order %q: %v", orderUID, err)
+		logger.FromContext(ctx).Warnf("failed to cache order %q: %v", orderUID, err)
 	}
 
 	return order, nil
 }
 
-func (s *Service) CreateOrder(ctx context.Context, order *entity.Order) error {
+func (s *Service) CreateOrder(ctx context.Context, order *entity.Order) (_ entity.CreateResult, err error) {
+	ctx = logger.WithOrderUID(ctx, order.OrderUID)
+	ctx, span := tracer.Start(ctx, "service.CreateOrder", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() { tracing.End(span, err) }()
+
 	// validate data
+	if err := ValidateOrder(order); err != nil {
+		return "", err
+	}
 
-	// дисклеймер: не до конца понимаю, что означают все поля структуры
-	// Order, следовательно не могу провалидировать входные данные,
-	// поэтому будем считать, что здесь происходит **DATA VALIDATION**
+	// new orders always start their lifecycle as created
+	order.Status = entity.OrderStatusCreated
 
 	// save new data to database
-	if err := s.repository.CreateOrder(ctx, order); err != nil {
-		return fmt.Errorf("create order in repository: %w", err)
+	result, err := s.repository.CreateOrder(ctx, order, s.policy)
+	if err != nil {
+		return "", fmt.Errorf("create order in repository: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("order.result", string(result)))
+
+	switch result {
+	case entity.CreateResultDuplicate:
+		return result, nil
+	case entity.CreateResultConflict:
+		logger.FromContext(ctx).Warnf("order %q already exists with different content, recorded as conflict", order.OrderUID)
+		return result, nil
 	}
 
 	// set new data to cache
 	data, err := json.Marshal(order)
 	if err != nil {
-		return fmt.Errorf("marshal new order: %w", err)
+		return result, fmt.Errorf("marshal new order: %w", err)
 	}
 
 	if err := s.cache.SetData(ctx, order.OrderUID, data); err != nil {
-		logrus.Warn("failed to cache order %q: %v", order.OrderUID, err)
+		logger.FromContext(ctx).Warnf("failed to cache order %q: %v", order.OrderUID, err)
 	}
 
-	return nil
+	if err := s.cache.Invalidate(ctx, order.OrderUID); err != nil {
+		logger.FromContext(ctx).Warnf("failed to publish cache invalidation for order %q: %v", order.OrderUID, err)
+	}
+
+	return result, nil
 }
 
-func (s *Service) PreloadCache(ctx context.Context) error {
-	orders, err := s.repository.GetAllOrders(ctx)
+// CreateOrders validates and stores a batch of orders at once, returning one
+// result per order in the same order. Orders that fail validation or storage
+// are reported in their result; an error means the whole batch failed.
+func (s *Service) CreateOrders(ctx context.Context, orders []*entity.Order) (_ []entity.BatchResult, err error) {
+	ctx, span := tracer.Start(ctx, "service.CreateOrders", trace.WithAttributes(attribute.Int("orders.count", len(orders))))
+	defer func() { tracing.End(span, err) }()
+
+	results := make([]entity.BatchResult, len(orders))
+
+	var (
+		valid   []*entity.Order
+		indexes []int
+	)
+	for i, order := range orders {
+		results[i].OrderUID = order.OrderUID
+
+		// validate data
+		if err := ValidateOrder(order); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		// new orders always start their lifecycle as created
+		order.Status = entity.OrderStatusCreated
+
+		valid = append(valid, order)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	// save new data to database
+	stored, err := s.repository.CreateOrders(ctx, valid, s.policy)
 	if err != nil {
-		return fmt.Errorf("failed to preload cache: %w", err)
+		return nil, fmt.Errorf("create orders in repository: %w", err)
 	}
 
-	for _, order := range orders {
-		data, err := json.Marshal(order)
-		if err != nil {
-			logrus.Warnf("failed to marshal order %q: %v", order.OrderUID, err)
+	entries := make(map[string][]byte)
+	for j, res := range stored {
+		i := indexes[j]
+
+		if res.Err != nil {
+			results[i].Err = fmt.Errorf("create order in repository: %w", res.Err)
 			continue
 		}
 
-		if err := s.cache.SetData(ctx, order.OrderUID, data); err != nil {
-			logrus.Warnf("failed to cache order %q: %v", order.OrderUID, err)
+		results[i].Result = res.Result
+		switch res.Result {
+		case entity.CreateResultCreated, entity.CreateResultUpdated:
+			data, err := json.Marshal(valid[j])
+			if err != nil {
+				logger.FromContext(ctx).Warnf("failed to marshal order %q for cache: %v", valid[j].OrderUID, err)
+				continue
+			}
+
+			entries[valid[j].OrderUID] = data
+		case entity.CreateResultConflict:
+			logger.FromContext(ctx).Warnf("order %q already exists with different content, recorded as conflict", valid[j].OrderUID)
+		}
+	}
+
+	// set new data to cache
+	if len(entries) > 0 {
+		if err := s.cache.SetMany(ctx, entries); err != nil {
+			logger.FromContext(ctx).Warnf("failed to cache %d orders: %v", len(entries), err)
+		}
+
+		for orderUID := range entries {
+			if err := s.cache.Invalidate(ctx, orderUID); err != nil {
+				logger.FromContext(ctx).Warnf("failed to publish cache invalidation for order %q: %v", orderUID, err)
+			}
 		}
 	}
 
-	logrus.Infof("Preloaded %d orders into cache", len(orders))
-	return nil
+	return results, nil
+}
+
+// GetOrderHistory returns the audit history of an order.
+func (s *Service) GetOrderHistory(ctx context.Context, orderUID string) ([]entity.AuditEntry, error) {
+	history, err := s.repository.GetAuditHistory(ctx, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("get history from repository: %w", err)
+	}
+
+	if len(history) == 0 {
+		return nil, fmt.Errorf("order %q: %w", orderUID, errs.ErrNotFound)
+	}
+
+	return history, nil
+}
+
+func (s *Service) ListOrders(ctx context.Context, filter entity.OrderFilter) (*entity.OrderPage, error) {
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = defaultPageSize
+	case filter.Limit > maxPageSize:
+		filter.Limit = maxPageSize
+	}
+
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "created_to", Message: "must be after created_from"}}}
+	}
+
+	page, err := s.repository.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list orders in repository: %w", err)
+	}
+
+	return page, nil
 }
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/cache"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/repository"
+)
+
+// countingRepository counts the loads that reach the repository and holds
+// each of them until release is closed, if set.
+type countingRepository struct {
+	*repository.MemoryRepository
+
+	loads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) GetOrder(ctx context.Context, orderUID string) (*entity.Order, error) {
+	r.loads.Add(1)
+
+	if r.release != nil {
+		<-r.release
+	}
+
+	return r.MemoryRepository.GetOrder(ctx, orderUID)
+}
+
+func newTestService(t *testing.T, cfg config.Service) (*Service, *countingRepository) {
+	t.Helper()
+
+	repo := &countingRepository{MemoryRepository: repository.NewMemoryRepository()}
+	c := cache.NewMemoryCache(config.Cache{LocalMaxEntries: 100})
+
+	return NewService(c, repo, cfg), repo
+}
+
+// clockCache keeps the orders cached as missing against a fake clock, so
+// that negative_ttl can expire without sleeping.
+type clockCache struct {
+	*cache.MemoryCache
+
+	now     time.Time
+	missing map[string]time.Time
+}
+
+func (c *clockCache) GetData(ctx context.Context, key string) ([]byte, error) {
+	if expiresAt, ok := c.missing[key]; ok && c.now.Before(expiresAt) {
+		return nil, errs.Wrap(errs.ErrNotFound, cache.ErrNegativeHit)
+	}
+
+	return c.MemoryCache.GetData(ctx, key)
+}
+
+func (c *clockCache) SetMissing(ctx context.Context, key string, ttl time.Duration) error {
+	c.missing[key] = c.now.Add(ttl)
+	return nil
+}
+
+func TestGetOrderCoalescesConcurrentLoads(t *testing.T) {
+	s, repo := newTestService(t, config.Service{ConflictPolicy: "reject", CoalesceReads: true})
+
+	order := validOrder("coalesced")
+	if _, err := repo.MemoryRepository.CreateOrder(context.Background(), order, entity.ConflictPolicyReject); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	repo.release = make(chan struct{})
+
+	const callers = 50
+
+	var (
+		wg       sync.WaitGroup
+		joined   sync.WaitGroup
+		failures atomic.Int32
+	)
+	joined.Add(callers)
+	s.loadJoined = joined.Done
+
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got, err := s.GetOrder(context.Background(), order.OrderUID)
+			if err != nil || got.OrderUID != order.OrderUID {
+				failures.Add(1)
+			}
+		}()
+	}
+
+	// hold the load until every caller has missed the cache and joined it
+	joined.Wait()
+	close(repo.release)
+	wg.Wait()
+
+	if n := failures.Load(); n > 0 {
+		t.Errorf("%d callers failed to get the order", n)
+	}
+
+	if n := repo.loads.Load(); n != 1 {
+		t.Errorf("expected 1 repository load, got %d", n)
+	}
+}
+
+func TestGetOrderCachesMissingOrders(t *testing.T) {
+	const negativeTTL = time.Minute
+
+	repo := &countingRepository{MemoryRepository: repository.NewMemoryRepository()}
+	c := &clockCache{
+		MemoryCache: cache.NewMemoryCache(config.Cache{LocalMaxEntries: 100}),
+		now:         time.Now(),
+		missing:     make(map[string]time.Time),
+	}
+	s := NewService(c, repo, config.Service{ConflictPolicy: "reject", CoalesceReads: true, NegativeTTL: negativeTTL})
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := s.GetOrder(ctx, "missing"); !errors.Is(err, errs.ErrNotFound) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	}
+
+	if n := repo.loads.Load(); n != 1 {
+		t.Fatalf("expected 1 repository load within negative_ttl, got %d", n)
+	}
+
+	c.now = c.now.Add(negativeTTL)
+
+	if _, err := s.GetOrder(ctx, "missing"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if n := repo.loads.Load(); n != 2 {
+		t.Errorf("expected a second repository load after negative_ttl, got %d loads", n)
+	}
+}
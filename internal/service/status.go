@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/pkg/logger"
+)
+
+// ErrIllegalTransition is returned when a status change is not allowed by
+// the order lifecycle.
+var ErrIllegalTransition = fmt.Errorf("illegal status transition: %w", errs.ErrConflict)
+
+// transitions lists the statuses an order may move to from each status.
+// Cancelled and returned orders are final.
+var transitions = map[entity.OrderStatus][]entity.OrderStatus{
+	entity.OrderStatusCreated:    {entity.OrderStatusPaid, entity.OrderStatusCancelled},
+	entity.OrderStatusPaid:       {entity.OrderStatusAssembling, entity.OrderStatusCancelled},
+	entity.OrderStatusAssembling: {entity.OrderStatusShipped, entity.OrderStatusCancelled},
+	entity.OrderStatusShipped:    {entity.OrderStatusDelivered, entity.OrderStatusReturned},
+	entity.OrderStatusDelivered:  {entity.OrderStatusReturned},
+	entity.OrderStatusCancelled:  {},
+	entity.OrderStatusReturned:   {},
+}
+
+// statusUpdateAttempts bounds how often UpdateStatus re-reads an order whose
+// status was changed concurrently.
+const statusUpdateAttempts = 3
+
+func isKnownStatus(status entity.OrderStatus) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+func canTransition(from, to entity.OrderStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateStatus moves an order to a new status if the lifecycle allows it.
+func (s *Service) UpdateStatus(ctx context.Context, orderUID string, to entity.OrderStatus) (*entity.Order, error) {
+	ctx = logger.WithOrderUID(ctx, orderUID)
+
+	if !isKnownStatus(to) {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "status", Message: fmt.Sprintf("unknown status %q", to)}}}
+	}
+
+	var order *entity.Order
+	for attempt := 1; ; attempt++ {
+		// read the current state from the database, the cache may be stale
+		var err error
+		order, err = s.repository.GetOrder(ctx, orderUID)
+		if err != nil {
+			if errors.Is(err, errs.ErrNotFound) {
+				return nil, fmt.Errorf("order %q: %w", orderUID, errs.ErrNotFound)
+			}
+
+			return nil, fmt.Errorf("get from repository: %w", err)
+		}
+
+		from := order.Status
+		if !canTransition(from, to) {
+			return nil, fmt.Errorf("order %q %s -> %s: %w", orderUID, from, to, ErrIllegalTransition)
+		}
+
+		err = s.repository.UpdateStatus(ctx, orderUID, from, to)
+		if err == nil {
+			break
+		}
+
+		// the status was changed by someone else after it was read, so the
+		// transition is evaluated again against the new status
+		if errors.Is(err, errs.ErrConflict) && attempt < statusUpdateAttempts {
+			logger.FromContext(ctx).Debugf("order %q changed status concurrently, retrying: %v", orderUID, err)
+			continue
+		}
+
+		return nil, fmt.Errorf("update status in repository: %w", err)
+	}
+
+	order.Status = to
+
+	// set new data to cache
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("marshal updated order: %w", err)
+	}
+
+	if err := s.cache.SetData(ctx, orderUID, data); err != nil {
+		logger.FromContext(ctx).Warnf("failed to cache order %q: %v", orderUID, err)
+	}
+
+	if err := s.cache.Invalidate(ctx, orderUID); err != nil {
+		logger.FromContext(ctx).Warnf("failed to publish cache invalidation for order %q: %v", orderUID, err)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/realdanielursul/order-service/config"
+	"github.com/realdanielursul/order-service/internal/cache"
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+	"github.com/realdanielursul/order-service/internal/repository"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to entity.OrderStatus
+		allowed  bool
+	}{
+		{entity.OrderStatusCreated, entity.OrderStatusPaid, true},
+		{entity.OrderStatusCreated, entity.OrderStatusCancelled, true},
+		{entity.OrderStatusCreated, entity.OrderStatusAssembling, false},
+		{entity.OrderStatusCreated, entity.OrderStatusShipped, false},
+		{entity.OrderStatusCreated, entity.OrderStatusCreated, false},
+		{entity.OrderStatusPaid, entity.OrderStatusAssembling, true},
+		{entity.OrderStatusPaid, entity.OrderStatusCancelled, true},
+		{entity.OrderStatusPaid, entity.OrderStatusCreated, false},
+		{entity.OrderStatusPaid, entity.OrderStatusDelivered, false},
+		{entity.OrderStatusAssembling, entity.OrderStatusShipped, true},
+		{entity.OrderStatusAssembling, entity.OrderStatusCancelled, true},
+		{entity.OrderStatusAssembling, entity.OrderStatusReturned, false},
+		{entity.OrderStatusShipped, entity.OrderStatusDelivered, true},
+		{entity.OrderStatusShipped, entity.OrderStatusReturned, true},
+		{entity.OrderStatusShipped, entity.OrderStatusCancelled, false},
+		{entity.OrderStatusDelivered, entity.OrderStatusReturned, true},
+		{entity.OrderStatusDelivered, entity.OrderStatusCancelled, false},
+		{entity.OrderStatusCancelled, entity.OrderStatusPaid, false},
+		{entity.OrderStatusCancelled, entity.OrderStatusCreated, false},
+		{entity.OrderStatusReturned, entity.OrderStatusDelivered, false},
+		{"unknown", entity.OrderStatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := canTransition(tt.from, tt.to); got != tt.allowed {
+				t.Errorf("expected allowed %t, got %t", tt.allowed, got)
+			}
+		})
+	}
+}
+
+// racingRepository moves an order to status concurrent right before the
+// first status update, as another consumer would.
+type racingRepository struct {
+	*repository.MemoryRepository
+
+	concurrent entity.OrderStatus
+	raced      bool
+}
+
+func (r *racingRepository) UpdateStatus(ctx context.Context, orderUID string, from, to entity.OrderStatus) error {
+	if !r.raced {
+		r.raced = true
+		if err := r.MemoryRepository.UpdateStatus(ctx, orderUID, from, r.concurrent); err != nil {
+			return err
+		}
+	}
+
+	return r.MemoryRepository.UpdateStatus(ctx, orderUID, from, to)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     entity.OrderStatus
+		concurrent entity.OrderStatus // status set by another writer before the update, if any
+		to         entity.OrderStatus
+		want       entity.OrderStatus
+		err        error
+	}{
+		{
+			name:   "allowed",
+			status: entity.OrderStatusCreated,
+			to:     entity.OrderStatusPaid,
+			want:   entity.OrderStatusPaid,
+		},
+		{
+			name:   "illegal",
+			status: entity.OrderStatusCreated,
+			to:     entity.OrderStatusDelivered,
+			want:   entity.OrderStatusCreated,
+			err:    ErrIllegalTransition,
+		},
+		{
+			name:   "unknown status",
+			status: entity.OrderStatusCreated,
+			to:     "lost",
+			want:   entity.OrderStatusCreated,
+			err:    errs.ErrValidation,
+		},
+		{
+			name:       "concurrent change still allows the transition",
+			status:     entity.OrderStatusCreated,
+			concurrent: entity.OrderStatusPaid,
+			to:         entity.OrderStatusCancelled,
+			want:       entity.OrderStatusCancelled,
+		},
+		{
+			name:       "concurrent change makes the transition illegal",
+			status:     entity.OrderStatusCreated,
+			concurrent: entity.OrderStatusCancelled,
+			to:         entity.OrderStatusPaid,
+			want:       entity.OrderStatusCancelled,
+			err:        ErrIllegalTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			repo := &racingRepository{MemoryRepository: repository.NewMemoryRepository(), concurrent: tt.concurrent, raced: tt.concurrent == ""}
+			s := NewService(cache.NewMemoryCache(config.Cache{LocalMaxEntries: 100}), repo, config.Service{ConflictPolicy: "reject"})
+
+			order := validOrder("status")
+			order.Status = tt.status
+			if _, err := repo.MemoryRepository.CreateOrder(ctx, order, entity.ConflictPolicyReject); err != nil {
+				t.Fatalf("create order: %v", err)
+			}
+
+			_, err := s.UpdateStatus(ctx, order.OrderUID, tt.to)
+			if tt.err == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			stored, err := repo.MemoryRepository.GetOrder(ctx, order.OrderUID)
+			if err != nil {
+				t.Fatalf("get order: %v", err)
+			}
+
+			if stored.Status != tt.want {
+				t.Errorf("expected status %s, got %s", tt.want, stored.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+)
+
+// OrderRepository is the durable order storage used by the service. Writes
+// are audited with the source attached to ctx by audit.WithSource.
+// repository.Repository (Postgres) and repository.MemoryRepository implement it.
+type OrderRepository interface {
+	CreateOrder(ctx context.Context, order *entity.Order, policy entity.ConflictPolicy) (entity.CreateResult, error)
+	CreateOrders(ctx context.Context, orders []*entity.Order, policy entity.ConflictPolicy) ([]entity.BatchResult, error)
+	GetOrder(ctx context.Context, orderUID string) (*entity.Order, error)
+	ListOrders(ctx context.Context, filter entity.OrderFilter) (*entity.OrderPage, error)
+	IterateOrders(ctx context.Context, filter entity.OrderFilter, fn func([]*entity.Order) error) error
+	UpdateStatus(ctx context.Context, orderUID string, from, to entity.OrderStatus) error
+	GetAuditHistory(ctx context.Context, orderUID string) ([]entity.AuditEntry, error)
+}
+
+// OrderCache stores serialized orders by order_uid. A miss is reported as
+// errs.ErrNotFound and a negative entry as cache.ErrNegativeHit. Invalidate
+// evicts the key from the local tiers of other instances.
+// cache.Cache (Redis) and cache.MemoryCache implement it.
+type OrderCache interface {
+	SetData(ctx context.Context, key string, value []byte) error
+	SetMany(ctx context.Context, entries map[string][]byte) error
+	GetData(ctx context.Context, key string) ([]byte, error)
+	SetMissing(ctx context.Context, key string, ttl time.Duration) error
+	Invalidate(ctx context.Context, key string) error
+}
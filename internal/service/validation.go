@@ -0,0 +1,192 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+)
+
+var (
+	orderUIDRegexp    = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	trackNumberRegexp = regexp.MustCompile(`^[A-Z0-9]{1,64}$`)
+	localeRegexp      = regexp.MustCompile(`^[a-z]{2}$`)
+	phoneRegexp       = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	emailRegexp       = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
+)
+
+// ISO 4217 active currency codes
+var currencies = toSet(
+	"AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN", "BAM", "BBD", "BDT", "BGN", "BHD",
+	"BIF", "BMD", "BND", "BOB", "BRL", "BSD", "BTN", "BWP", "BYN", "BZD", "CAD", "CDF", "CHF", "CLP", "CNY",
+	"COP", "CRC", "CUP", "CVE", "CZK", "DJF", "DKK", "DOP", "DZD", "EGP", "ERN", "ETB", "EUR", "FJD", "FKP",
+	"GBP", "GEL", "GHS", "GIP", "GMD", "GNF", "GTQ", "GYD", "HKD", "HNL", "HTG", "HUF", "IDR", "ILS", "INR",
+	"IQD", "IRR", "ISK", "JMD", "JOD", "JPY", "KES", "KGS", "KHR", "KMF", "KPW", "KRW", "KWD", "KYD", "KZT",
+	"LAK", "LBP", "LKR", "LRD", "LSL", "LYD", "MAD", "MDL", "MGA", "MKD", "MMK", "MNT", "MOP", "MRU", "MUR",
+	"MVR", "MWK", "MXN", "MYR", "MZN", "NAD", "NGN", "NIO", "NOK", "NPR", "NZD", "OMR", "PAB", "PEN", "PGK",
+	"PHP", "PKR", "PLN", "PYG", "QAR", "RON", "RSD", "RUB", "RWF", "SAR", "SBD", "SCR", "SDG", "SEK", "SGD",
+	"SHP", "SLE", "SOS", "SRD", "SSP", "STN", "SVC", "SYP", "SZL", "THB", "TJS", "TMT", "TND", "TOP", "TRY",
+	"TTD", "TWD", "TZS", "UAH", "UGX", "USD", "UYU", "UZS", "VES", "VND", "VUV", "WST", "XAF", "XCD", "XOF",
+	"XPF", "YER", "ZAR", "ZMW", "ZWL",
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of an order that failed validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return errs.ErrValidation
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		e.add(field, "is required")
+		return false
+	}
+
+	return true
+}
+
+func (e *ValidationError) nonNegative(field string, value int) {
+	if value < 0 {
+		e.add(field, "must not be negative, got %d", value)
+	}
+}
+
+// ValidateOrder checks an incoming order and returns a *ValidationError
+// describing all violations, or nil if the order is valid.
+func ValidateOrder(order *entity.Order) error {
+	v := &ValidationError{}
+
+	// order data
+	if v.required("order_uid", order.OrderUID) && !orderUIDRegexp.MatchString(order.OrderUID) {
+		v.add("order_uid", "must be 1-64 latin letters, digits, '-' or '_'")
+	}
+
+	if v.required("track_number", order.TrackNumber) && !trackNumberRegexp.MatchString(order.TrackNumber) {
+		v.add("track_number", "must be 1-64 uppercase latin letters or digits")
+	}
+
+	v.required("entry", order.Entry)
+	v.required("customer_id", order.CustomerID)
+	v.required("delivery_service", order.DeliveryService)
+
+	if v.required("locale", order.Locale) && !localeRegexp.MatchString(order.Locale) {
+		v.add("locale", "must be a two-letter lowercase language code")
+	}
+
+	v.nonNegative("sm_id", order.SmID)
+
+	if order.DateCreated.IsZero() {
+		v.add("date_created", "is required")
+	}
+
+	if order.Status != "" && order.Status != entity.OrderStatusCreated {
+		v.add("status", "must be empty or %q for a new order", entity.OrderStatusCreated)
+	}
+
+	// delivery data
+	v.required("delivery.name", order.Delivery.Name)
+	v.required("delivery.city", order.Delivery.City)
+	v.required("delivery.address", order.Delivery.Address)
+
+	if v.required("delivery.phone", order.Delivery.Phone) && !phoneRegexp.MatchString(order.Delivery.Phone) {
+		v.add("delivery.phone", "must be in international format, e.g. +79990000000")
+	}
+
+	if v.required("delivery.email", order.Delivery.Email) && !emailRegexp.MatchString(order.Delivery.Email) {
+		v.add("delivery.email", "must be a valid email address")
+	}
+
+	// payment data
+	p := order.Payment
+	v.required("payment.transaction", p.Transaction)
+	v.required("payment.provider", p.Provider)
+
+	if v.required("payment.currency", p.Currency) && !currencies[p.Currency] {
+		v.add("payment.currency", "must be an ISO 4217 currency code, got %q", p.Currency)
+	}
+
+	v.nonNegative("payment.amount", p.Amount)
+	v.nonNegative("payment.delivery_cost", p.DeliveryCost)
+	v.nonNegative("payment.goods_total", p.GoodsTotal)
+	v.nonNegative("payment.custom_fee", p.CustomFee)
+
+	if p.PaymentDt <= 0 {
+		v.add("payment.payment_dt", "must be a positive unix timestamp")
+	}
+
+	// items data
+	if len(order.Items) == 0 {
+		v.add("items", "must contain at least one item")
+	}
+
+	goodsTotal := 0
+	for i, item := range order.Items {
+		field := fmt.Sprintf("items[%d]", i)
+
+		v.required(field+".name", item.Name)
+		v.required(field+".rid", item.RID)
+		v.nonNegative(field+".price", item.Price)
+		v.nonNegative(field+".total_price", item.TotalPrice)
+
+		if item.ChrtID <= 0 {
+			v.add(field+".chrt_id", "must be positive")
+		}
+
+		if item.Sale < 0 || item.Sale > 100 {
+			v.add(field+".sale", "must be a percentage between 0 and 100, got %d", item.Sale)
+		}
+
+		if item.TrackNumber != order.TrackNumber {
+			v.add(field+".track_number", "must match the order track_number")
+		}
+
+		goodsTotal += item.TotalPrice
+	}
+
+	// cross-field checks
+	if len(order.Items) > 0 && p.GoodsTotal != goodsTotal {
+		v.add("payment.goods_total", "must equal the sum of item total_price (%d), got %d", goodsTotal, p.GoodsTotal)
+	}
+
+	if expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != expected {
+		v.add("payment.amount", "must equal goods_total + delivery_cost + custom_fee (%d), got %d", expected, p.Amount)
+	}
+
+	if len(v.Fields) > 0 {
+		return v
+	}
+
+	return nil
+}
+
+func toSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/realdanielursul/order-service/internal/entity"
+	"github.com/realdanielursul/order-service/internal/errs"
+)
+
+func validOrder(orderUID string) *entity.Order {
+	return &entity.Order{
+		OrderUID:        orderUID,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: entity.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: entity.Payment{
+			Transaction:  orderUID,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []entity.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NMID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *entity.Order)
+		fields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(o *entity.Order) {},
+		},
+		{
+			name:   "created status",
+			modify: func(o *entity.Order) { o.Status = entity.OrderStatusCreated },
+		},
+		{
+			name:   "missing order_uid",
+			modify: func(o *entity.Order) { o.OrderUID = " " },
+			fields: []string{"order_uid"},
+		},
+		{
+			name:   "malformed order_uid",
+			modify: func(o *entity.Order) { o.OrderUID = "order/1" },
+			fields: []string{"order_uid"},
+		},
+		{
+			name: "lowercase track_number",
+			modify: func(o *entity.Order) {
+				o.TrackNumber = "wbil"
+				o.Items[0].TrackNumber = "wbil"
+			},
+			fields: []string{"track_number"},
+		},
+		{
+			name:   "bad locale",
+			modify: func(o *entity.Order) { o.Locale = "eng" },
+			fields: []string{"locale"},
+		},
+		{
+			name:   "missing date_created",
+			modify: func(o *entity.Order) { o.DateCreated = time.Time{} },
+			fields: []string{"date_created"},
+		},
+		{
+			name:   "advanced status",
+			modify: func(o *entity.Order) { o.Status = entity.OrderStatusPaid },
+			fields: []string{"status"},
+		},
+		{
+			name: "bad contacts",
+			modify: func(o *entity.Order) {
+				o.Delivery.Phone = "89990000000"
+				o.Delivery.Email = "test@"
+			},
+			fields: []string{"delivery.phone", "delivery.email"},
+		},
+		{
+			name:   "unknown currency",
+			modify: func(o *entity.Order) { o.Payment.Currency = "XXX" },
+			fields: []string{"payment.currency"},
+		},
+		{
+			name:   "missing payment_dt",
+			modify: func(o *entity.Order) { o.Payment.PaymentDt = 0 },
+			fields: []string{"payment.payment_dt"},
+		},
+		{
+			name: "no items",
+			modify: func(o *entity.Order) {
+				o.Items = nil
+				o.Payment.GoodsTotal = 0
+				o.Payment.Amount = o.Payment.DeliveryCost
+			},
+			fields: []string{"items"},
+		},
+		{
+			name: "bad item",
+			modify: func(o *entity.Order) {
+				o.Items[0].ChrtID = 0
+				o.Items[0].Sale = 101
+				o.Items[0].TrackNumber = "OTHER"
+			},
+			fields: []string{"items[0].chrt_id", "items[0].sale", "items[0].track_number"},
+		},
+		{
+			name:   "goods_total mismatch",
+			modify: func(o *entity.Order) { o.Items[0].TotalPrice = 300 },
+			fields: []string{"payment.goods_total"},
+		},
+		{
+			name:   "amount mismatch",
+			modify: func(o *entity.Order) { o.Payment.Amount = 1 },
+			fields: []string{"payment.amount"},
+		},
+		{
+			name: "negative amounts",
+			modify: func(o *entity.Order) {
+				o.Payment.CustomFee = -1
+				o.Payment.Amount = o.Payment.GoodsTotal + o.Payment.DeliveryCost - 1
+			},
+			fields: []string{"payment.custom_fee"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder("b563feb7b2b84b6test")
+			tt.modify(order)
+
+			err := ValidateOrder(order)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %v", err)
+			}
+
+			if !errors.Is(err, errs.ErrValidation) {
+				t.Errorf("expected error to match errs.ErrValidation")
+			}
+
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("expected fields %v, got %v (%v)", tt.fields, fields, err)
+			}
+		})
+	}
+}
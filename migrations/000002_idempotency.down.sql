@@ -0,0 +1,3 @@
+DROP TABLE order_conflicts;
+
+ALTER TABLE orders DROP COLUMN content_hash;
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS order_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    existing_hash TEXT,
+    incoming_hash TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_conflicts_order_uid_idx ON order_conflicts (order_uid);
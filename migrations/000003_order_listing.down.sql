@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS items_brand_idx;
+
+DROP INDEX IF EXISTS items_order_uid_idx;
+
+DROP INDEX IF EXISTS payment_bank_idx;
+
+DROP INDEX IF EXISTS payment_provider_idx;
+
+DROP INDEX IF EXISTS payment_currency_idx;
+
+DROP INDEX IF EXISTS payment_order_uid_idx;
+
+DROP INDEX IF EXISTS delivery_order_uid_idx;
+
+DROP INDEX IF EXISTS orders_locale_idx;
+
+DROP INDEX IF EXISTS orders_delivery_service_idx;
+
+DROP INDEX IF EXISTS orders_customer_id_idx;
+
+DROP INDEX IF EXISTS orders_date_created_idx;
\ No newline at end of file
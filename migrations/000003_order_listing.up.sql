@@ -0,0 +1,21 @@
+CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created DESC, order_uid DESC);
+
+CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id, date_created DESC);
+
+CREATE INDEX IF NOT EXISTS orders_delivery_service_idx ON orders (delivery_service);
+
+CREATE INDEX IF NOT EXISTS orders_locale_idx ON orders (locale);
+
+CREATE INDEX IF NOT EXISTS delivery_order_uid_idx ON delivery (order_uid);
+
+CREATE INDEX IF NOT EXISTS payment_order_uid_idx ON payment (order_uid);
+
+CREATE INDEX IF NOT EXISTS payment_currency_idx ON payment (currency);
+
+CREATE INDEX IF NOT EXISTS payment_provider_idx ON payment (provider);
+
+CREATE INDEX IF NOT EXISTS payment_bank_idx ON payment (bank);
+
+CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid);
+
+CREATE INDEX IF NOT EXISTS items_brand_idx ON items (brand, order_uid);
\ No newline at end of file
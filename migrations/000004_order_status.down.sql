@@ -0,0 +1,3 @@
+DROP TABLE order_status_history;
+
+ALTER TABLE orders DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE order_audit;
\ No newline at end of file
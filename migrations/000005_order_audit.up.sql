@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS order_audit (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    action TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    source TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_audit_order_uid_idx ON order_audit (order_uid, id);
\ No newline at end of file
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check reports whether a dependency is usable. A nil error means it is.
+type Check func(ctx context.Context) error
+
+// Checker runs a set of named checks concurrently, each within a timeout.
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]Check
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all checks. Ready is set only if every check
+// passed.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Add registers check under name, replacing a check of the same name.
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+
+	c.checks[name] = check
+}
+
+// Run runs all checks and collects their results.
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = Report{Ready: true, Checks: make(map[string]Result, len(c.names))}
+	)
+
+	for _, name := range c.names {
+		check := c.checks[name]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+
+			res := Result{Status: StatusUp, Duration: time.Since(start).Round(time.Microsecond).String()}
+			if err != nil {
+				res.Status, res.Error = StatusDown, err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = res
+			if err != nil {
+				report.Ready = false
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
@@ -6,6 +6,9 @@ import (
 	"time"
 )
 
+// WriteTimeout bounds how long a request may take to be handled and answered.
+const WriteTimeout = 10 * time.Second
+
 type Server struct {
 	httpServer *http.Server
 }
@@ -16,12 +19,16 @@ func (s *Server) Run(port string, handler http.Handler) error {
 		Handler:        handler,
 		MaxHeaderBytes: 1 << 20, // 1 MB
 		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		WriteTimeout:   WriteTimeout,
 	}
 
 	return s.httpServer.ListenAndServe()
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+
 	return s.httpServer.Shutdown(ctx)
 }
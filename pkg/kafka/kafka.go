@@ -1,15 +1,51 @@
 package kafka
 
 import (
+	"context"
+	"time"
+
 	"github.com/realdanielursul/order-service/config"
 	"github.com/segmentio/kafka-go"
 )
 
-func NewKafkaReader(cfg config.Kafka) *kafka.Reader {
+// writerBatchTimeout bounds how long a write waits for more messages to fill
+// its batch. Writes are synchronous, so the kafka-go default of a second
+// would delay every one of them.
+const writerBatchTimeout = 10 * time.Millisecond
+
+func NewKafkaReader(cfg config.Kafka, topic string) *kafka.Reader {
 	return kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     []string{cfg.Host + ":" + cfg.Port},
-		Topic:       cfg.Topic,
+		Topic:       topic,
 		GroupID:     cfg.GroupID,
 		StartOffset: kafka.LastOffset,
 	})
 }
+
+func NewKafkaWriter(cfg config.Kafka, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.Host + ":" + cfg.Port),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           writerBatchTimeout,
+	}
+}
+
+// Ping checks that the broker accepts connections and answers a metadata
+// request.
+func Ping(ctx context.Context, cfg config.Kafka) error {
+	conn, err := kafka.DialContext(ctx, "tcp", cfg.Host+":"+cfg.Port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Brokers()
+	return err
+}
@@ -0,0 +1,103 @@
+package lifecycle
+
+import (
+	"context"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultStepTimeout = 10 * time.Second
+
+// Manager owns the root context of the app, which is cancelled on SIGTERM or
+// SIGINT, and shuts the app components down in a fixed order.
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+
+	tasks sync.WaitGroup
+	hooks []hook
+}
+
+type hook struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// New creates a manager. timeout bounds every shutdown step that does not
+// set its own.
+func New(timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = defaultStepTimeout
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+
+	return &Manager{ctx: ctx, cancel: cancel, timeout: timeout}
+}
+
+// Context returns the root context, done once shutdown has begun.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs fn in the background with the root context. Shutdown waits for it
+// to return before running the hooks.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.tasks.Add(1)
+
+	go func() {
+		defer m.tasks.Done()
+		defer logrus.Debugf("%s stopped", name)
+
+		fn(m.ctx)
+	}()
+}
+
+// OnShutdown registers fn to run on shutdown within timeout, or within the
+// manager timeout if it is zero. Hooks run one after another in the order
+// they were registered.
+func (m *Manager) OnShutdown(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	if timeout <= 0 {
+		timeout = m.timeout
+	}
+
+	m.hooks = append(m.hooks, hook{name: name, timeout: timeout, fn: fn})
+}
+
+// Wait blocks until a shutdown signal is received.
+func (m *Manager) Wait() {
+	<-m.ctx.Done()
+}
+
+// Shutdown cancels the root context, waits for the background tasks and runs
+// the hooks. A step that fails or runs out of time is logged and does not
+// stop the following ones.
+func (m *Manager) Shutdown() {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(m.timeout):
+		logrus.Warnf("background tasks did not stop within %s", m.timeout)
+	}
+
+	for _, h := range m.hooks {
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		if err := h.fn(ctx); err != nil {
+			logrus.Errorf("error occured on %s shutting down: %s", h.name, err.Error())
+		}
+		cancel()
+	}
+}
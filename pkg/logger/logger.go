@@ -1,15 +1,114 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"os"
 
+	"github.com/realdanielursul/order-service/config"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SetLogrus() {
-	logrus.SetFormatter(&logrus.JSONFormatter{
-		TimestampFormat: "2006-01-02 15:04:05",
-	})
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	timestampFormat = "2006-01-02 15:04:05"
+)
+
+// Fields attached to log entries by the helpers below.
+const (
+	FieldOrderUID       = "order_uid"
+	FieldRequestID      = "request_id"
+	FieldKafkaTopic     = "kafka_topic"
+	FieldKafkaPartition = "kafka_partition"
+	FieldKafkaOffset    = "kafka_offset"
+	FieldTraceID        = "trace_id"
+	FieldSpanID         = "span_id"
+)
+
+type fieldsKey struct{}
+
+// SetLogrus configures the standard logger with the level and format from
+// cfg. An empty level means info and an empty format means JSON.
+func SetLogrus(cfg config.Log) error {
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		var err error
+		if level, err = logrus.ParseLevel(cfg.Level); err != nil {
+			return err
+		}
+	}
 
+	switch cfg.Format {
+	case "", FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: timestampFormat,
+		})
+	case FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: timestampFormat,
+		})
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	logrus.SetLevel(level)
 	logrus.SetOutput(os.Stdout)
+
+	return nil
+}
+
+// WithFields returns a copy of ctx whose logger adds fields to every entry,
+// on top of the fields already attached to ctx.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := make(logrus.Fields, len(fields))
+	if parent, ok := ctx.Value(fieldsKey{}).(logrus.Fields); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func WithOrderUID(ctx context.Context, orderUID string) context.Context {
+	return WithFields(ctx, logrus.Fields{FieldOrderUID: orderUID})
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return WithFields(ctx, logrus.Fields{FieldRequestID: requestID})
+}
+
+func WithKafkaMessage(ctx context.Context, topic string, partition int, offset int64) context.Context {
+	return WithFields(ctx, logrus.Fields{
+		FieldKafkaTopic:     topic,
+		FieldKafkaPartition: partition,
+		FieldKafkaOffset:    offset,
+	})
+}
+
+// FromContext returns a logger with the fields attached to ctx and the IDs
+// of the active trace span, if any.
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+
+	if fields, ok := ctx.Value(fieldsKey{}).(logrus.Fields); ok {
+		entry = entry.WithFields(fields)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			FieldTraceID: sc.TraceID().String(),
+			FieldSpanID:  sc.SpanID().String(),
+		})
+	}
+
+	return entry.WithContext(ctx)
 }
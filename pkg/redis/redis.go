@@ -7,15 +7,18 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-func NewRedisClient(cfg config.Redis) (*redis.Client, error) {
+// NewRedisClient creates a client and checks that redis is reachable within
+// ctx.
+func NewRedisClient(ctx context.Context, cfg config.Redis) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.Host + ":" + cfg.Port,
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
 
-	_, err := client.Ping(context.Background()).Result()
+	_, err := client.Ping(ctx).Result()
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
@@ -0,0 +1,60 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+type Policy struct {
+	MaxAttempts int // 0 means retry until the context is done
+	Initial     time.Duration
+	Max         time.Duration
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable,
+// the attempts are exhausted or ctx is done. The delay between attempts grows
+// exponentially from Initial up to Max with a random jitter.
+func Do(ctx context.Context, p Policy, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	delay := p.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !retryable(err) {
+			return err
+		}
+
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+
+		if err := Sleep(ctx, jitter(delay)); err != nil {
+			return err
+		}
+
+		delay *= 2
+		if p.Max > 0 && delay > p.Max {
+			delay = p.Max
+		}
+	}
+}
+
+// Sleep pauses for d or until ctx is done, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
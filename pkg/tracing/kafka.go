@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// KafkaCarrier exposes Kafka message headers to the propagator.
+type KafkaCarrier struct {
+	Headers *[]kafka.Header
+}
+
+func (c KafkaCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+func (c KafkaCarrier) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+
+	*c.Headers = append(*c.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c KafkaCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+
+	return keys
+}
+
+// ExtractKafka returns ctx carrying the trace context found in the headers
+// of m, if any.
+func ExtractKafka(ctx context.Context, m kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, KafkaCarrier{Headers: &m.Headers})
+}